@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+	"github.com/segmentio/kafka-go"
+)
+
+// deadletter inspects or replays the messages in the dead-letter topic. "inspect" prints
+// every dead letter as a JSON line; "replay" republishes the original messages to the
+// topic they came from. Further arguments are document IDs or page URLs that limit
+// either command to those documents.
+func main() {
+	logger := telemetry.NewStdLogger()
+
+	if len(os.Args) < 2 || (os.Args[1] != "inspect" && os.Args[1] != "replay") {
+		logger.Error("deadletter_failed", os.ErrInvalid, "reason", "usage: deadletter inspect|replay [document IDs or URLs]")
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	keys := make(map[string]bool)
+	for _, target := range os.Args[2:] {
+		if strings.Contains(target, "://") {
+			target = crawler.DocumentID(target)
+		}
+		keys[target] = true
+	}
+
+	brokers := splitAndTrim(envOrDefault("KAFKA_BROKERS", "localhost:9092"))
+	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
+	deadLetterTopic := envOrDefault("KAFKA_DEADLETTER_TOPIC", topic+".dlq")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	writer := &kafka.Writer{Addr: kafka.TCP(brokers...), RequiredAcks: kafka.RequireAll}
+	defer writer.Close()
+	encoder := json.NewEncoder(os.Stdout)
+
+	count := 0
+	err := pipeline.ReadTopic(ctx, brokers, deadLetterTopic, func(m kafka.Message) error {
+		d := pipeline.ParseDeadLetter(m)
+		if len(keys) > 0 && !keys[string(d.Message.Key)] {
+			return nil
+		}
+		count++
+		if command == "inspect" {
+			return encoder.Encode(inspect(d))
+		}
+		target := d.Topic
+		if target == "" {
+			target = topic
+		}
+		msg := d.Message
+		msg.Topic = target
+		if err := writer.WriteMessages(ctx, msg); err != nil {
+			return err
+		}
+		logger.Info("deadletter_replayed", "key", string(msg.Key), "topic", target, "partition", d.Partition, "offset", d.Offset)
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("deadletter_failed", err, "command", command, "topic", deadLetterTopic)
+		os.Exit(1)
+	}
+	logger.Info("deadletter_done", "command", command, "topic", deadLetterTopic, "messages", count)
+}
+
+// inspection is how inspect prints a dead letter.
+type inspection struct {
+	Partition       int       `json:"partition"`
+	Offset          int64     `json:"offset"`
+	Key             string    `json:"key"`
+	Topic           string    `json:"topic"`
+	SourcePartition int       `json:"source_partition"`
+	SourceOffset    int64     `json:"source_offset"`
+	Stage           string    `json:"stage"`
+	Error           string    `json:"error"`
+	Attempts        int       `json:"attempts"`
+	FailedAt        time.Time `json:"failed_at"`
+	Value           string    `json:"value"`
+}
+
+func inspect(d pipeline.DeadLetter) inspection {
+	return inspection{
+		Partition:       d.Partition,
+		Offset:          d.Offset,
+		Key:             string(d.Message.Key),
+		Topic:           d.Topic,
+		SourcePartition: d.SourcePartition,
+		SourceOffset:    d.SourceOffset,
+		Stage:           d.Stage,
+		Error:           d.Error,
+		Attempts:        d.Attempts,
+		FailedAt:        d.FailedAt,
+		Value:           string(d.Message.Value),
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/importer"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+func main() {
+	telemetry.RegisterMetrics()
+	logger := telemetry.NewStdLogger()
+
+	inputPath := os.Getenv("IMPORT_FILE")
+	if inputPath == "" && len(os.Args) > 1 {
+		inputPath = os.Args[1]
+	}
+	mappingPath := os.Getenv("IMPORT_MAPPING")
+	if inputPath == "" || mappingPath == "" {
+		logger.Error("import_failed", os.ErrInvalid, "reason", "set IMPORT_FILE and IMPORT_MAPPING")
+		os.Exit(2)
+	}
+
+	mapping, err := importer.LoadFieldMapping(mappingPath)
+	if err != nil {
+		logger.Error("mapping_load_failed", err, "path", mappingPath)
+		os.Exit(1)
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		logger.Error("import_open_failed", err, "path", inputPath)
+		os.Exit(1)
+	}
+	defer input.Close()
+
+	format := envOrDefault("IMPORT_FORMAT", strings.TrimPrefix(strings.ToLower(filepath.Ext(inputPath)), "."))
+	var reader importer.RecordReader
+	switch format {
+	case "jsonl", "ndjson", "json":
+		reader = importer.NewJSONLReader(input)
+	case "csv":
+		csvReader, err := importer.NewCSVReader(input)
+		if err != nil {
+			logger.Error("import_open_failed", err, "path", inputPath)
+			os.Exit(1)
+		}
+		reader = csvReader
+	default:
+		logger.Error("import_failed", os.ErrInvalid, "reason", "unknown IMPORT_FORMAT", "format", format)
+		os.Exit(2)
+	}
+
+	rejectsPath := envOrDefault("IMPORT_REJECTS", inputPath+".rejected.jsonl")
+	if err := os.Remove(rejectsPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("rejects_open_failed", err, "path", rejectsPath)
+		os.Exit(1)
+	}
+	rejects := &lazyFile{path: rejectsPath}
+	defer rejects.Close()
+
+	brokers := splitAndTrim(envOrDefault("KAFKA_BROKERS", "localhost:9092"))
+	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
+	sink := pipeline.NewKafkaSink(brokers, topic, logger)
+	sink.Source = "importer"
+	sink.Codec = envOrDefault("KAFKA_EVENT_CODEC", pipeline.CodecJSON)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	logger.Info("import_start", "path", inputPath, "format", format, "topic", topic)
+	stats, err := importer.ImportRecords(ctx, reader, sink, importer.RecordImportOptions{
+		Mapping:       mapping,
+		Rejects:       rejects,
+		ProgressEvery: envInt("IMPORT_PROGRESS_EVERY", 1000),
+		Logger:        logger,
+	})
+	sink.Close()
+	logger.Info("import_complete", "read", stats.Read, "published", stats.Published, "rejected", stats.Rejected, "rejects", rejectsPath, "duration_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		logger.Error("import_failed", err, "path", inputPath)
+		os.Exit(1)
+	}
+}
+
+// lazyFile creates its file on the first write, so an import without rejects leaves
+// no empty rejects file behind.
+type lazyFile struct {
+	path string
+	file *os.File
+}
+
+func (f *lazyFile) Write(p []byte) (int, error) {
+	if f.file == nil {
+		file, err := os.Create(f.path)
+		if err != nil {
+			return 0, err
+		}
+		f.file = file
+	}
+	return f.file.Write(p)
+}
+
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+func envOrDefault(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		n, err := strconv.Atoi(val)
+		if err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
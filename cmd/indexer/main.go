@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/index"
 	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
 	"github.com/eshwanth/distributed-search-engine/internal/semantic"
@@ -22,21 +26,55 @@ func main() {
 
 	brokers := splitAndTrim(envOrDefault("KAFKA_BROKERS", "localhost:9092"))
 	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
-	group := envOrDefault("INDEXER_GROUP", "indexer-service")
 
-	consumer := pipeline.NewKafkaConsumer(brokers, topic, group, logger)
-	defer consumer.Close()
-
-	idx := index.NewInvertedIndex()
-	sem := semantic.NewIndex(semantic.Options{Dimension: 128, HyperplaneCount: 24})
+	analyzer, err := analysis.ByName(os.Getenv("INDEX_ANALYZER"), os.Getenv("INDEX_CJK_MODE"))
+	if err != nil {
+		logger.Error("indexer_config_invalid", err, "analyzer", os.Getenv("INDEX_ANALYZER"), "cjk_mode", os.Getenv("INDEX_CJK_MODE"))
+		os.Exit(2)
+	}
+	idx := index.NewShardedIndex(envInt("INDEX_SHARDS", 1))
+	idx.SetAnalyzer(analyzer)
+	sem := semantic.NewIndex(semantic.Options{Dimension: 128, HyperplaneCount: 24, Analyzer: analyzer})
+	images := index.NewInvertedIndex()
+	images.Analyzer = analyzer
 
-	snapshotPath := envOrDefault("SNAPSHOT_PATH", filepath.Join("data", "index.snapshot.json"))
+	snapshotPath := envOrDefault("SNAPSHOT_PATH", filepath.Join("data", "index.snapshot"))
 	snapshotInterval := envDuration("SNAPSHOT_INTERVAL", time.Minute)
 
+	// The indexer resumes from its own snapshot: the snapshot records the offset of every
+	// partition it contains, and reading continues from exactly there. Without a
+	// recorded position the topic is replayed from the start.
+	consumer := pipeline.NewBroadcastKafkaConsumer(brokers, topic, logger)
+	consumer.DeadLetterTopic = envOrDefault("KAFKA_DEADLETTER_TOPIC", topic+".dlq")
+	consumer.MaxRetries = envInt("KAFKA_HANDLER_RETRIES", consumer.MaxRetries)
+	consumer.RetryBackoff = envDuration("KAFKA_RETRY_BACKOFF", consumer.RetryBackoff)
+	defer consumer.Close()
+	snapshot, err := index.LoadSnapshot(snapshotPath)
+	switch {
+	case err == nil:
+		if snapshot.PrimaryErr != nil {
+			logger.Error("snapshot_fallback", snapshot.PrimaryErr, "path", snapshotPath, "loaded", snapshot.Path)
+		}
+		if err := restoreSnapshot(snapshot, idx, images, sem); err != nil {
+			logger.Error("snapshot_restore_failed", err, "path", snapshot.Path)
+		}
+		if pos, ok, err := snapshot.Position(); err != nil {
+			logger.Error("snapshot_position_invalid", err, "path", snapshot.Path)
+		} else if ok && pos.Topic == topic {
+			consumer.StartOffsets = pos.Offsets
+		} else {
+			logger.Info("snapshot_position_missing", "path", snapshot.Path, "topic", topic)
+		}
+		logger.Info("snapshot_loaded", "path", snapshot.Path, "version", snapshot.Version, "documents", len(snapshot.Documents))
+	case !errors.Is(err, fs.ErrNotExist):
+		logger.Error("snapshot_load_failed", err, "path", snapshotPath)
+	}
+
 	updater := &pipeline.IndexUpdater{
 		Consumer:      consumer,
 		Index:         idx,
 		Semantic:      sem,
+		Images:        images,
 		SnapshotPath:  snapshotPath,
 		SnapshotEvery: snapshotInterval,
 		Logger:        logger,
@@ -45,6 +83,10 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	refreshInterval := envDuration("INDEX_REFRESH_INTERVAL", time.Second)
+	go idx.RunMerger(ctx, refreshInterval)
+	go images.RunMerger(ctx, refreshInterval)
+
 	metricsAddr := envOrDefault("METRICS_ADDR", ":9101")
 	go func() {
 		logger.Info("indexer_metrics_listening", "addr", metricsAddr)
@@ -53,13 +95,42 @@ func main() {
 		}
 	}()
 
-	logger.Info("indexer_started", "topic", topic, "group", group, "snapshot", snapshotPath)
+	logger.Info("indexer_started", "topic", topic, "snapshot", snapshotPath, "offsets", consumer.StartOffsets, "shards", len(idx.Shards()))
 	if err := updater.Run(ctx); err != nil {
 		logger.Error("indexer_failed", err)
+		os.Exit(1)
 	}
 	logger.Info("indexer_shutdown")
 }
 
+// restoreSnapshot installs the prebuilt indexes of the indexer's last snapshot, and
+// re-indexes its documents into any that fail to restore.
+func restoreSnapshot(snapshot *index.LoadedSnapshot, idx *index.ShardedIndex, images *index.InvertedIndex, sem *semantic.Index) error {
+	pagesRestored, pagesErr := snapshot.RestoreShardedIndex(index.SnapshotPages, idx)
+	imagesRestored, imagesErr := snapshot.RestoreIndex(index.SnapshotImages, images)
+	var semanticErr error
+	semanticRestored := false
+	if data, ok := snapshot.Blob(index.SnapshotSemantic); ok && snapshot.Version >= 3 {
+		semanticErr = sem.UnmarshalBinary(data)
+		semanticRestored = semanticErr == nil
+	}
+	for _, doc := range snapshot.Documents {
+		if doc.IsImage() {
+			if !imagesRestored {
+				images.AddDocument(doc)
+			}
+			continue
+		}
+		if !pagesRestored {
+			idx.AddDocument(doc)
+		}
+		if !semanticRestored {
+			sem.AddDocument(doc)
+		}
+	}
+	return errors.Join(pagesErr, imagesErr, semanticErr)
+}
+
 func envOrDefault(key, fallback string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -88,3 +159,13 @@ func envDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+func envInt(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		n, err := strconv.Atoi(val)
+		if err == nil {
+			return n
+		}
+	}
+	return fallback
+}
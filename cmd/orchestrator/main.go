@@ -5,10 +5,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
 	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/semantic"
 	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
 )
 
@@ -21,9 +24,25 @@ func main() {
 	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
 
 	sink := pipeline.NewKafkaSink(brokers, topic, logger)
+	sink.Source = "orchestrator"
+	sink.Codec = envOrDefault("KAFKA_EVENT_CODEC", pipeline.CodecJSON)
 	defer sink.Close()
 
 	orch := pipeline.NewCrawlerOrchestrator(logger, sink)
+	if path := os.Getenv("CRAWL_CREDENTIALS"); path != "" {
+		store, err := crawler.LoadCredentialStore(path)
+		if err != nil {
+			logger.Error("credentials_load_failed", err, "path", path)
+			os.Exit(1)
+		}
+		orch.Crawler.Fetcher = &crawler.HTTPFetcher{Credentials: store}
+		logger.Info("credentials_loaded", "path", path)
+	}
+	if topic := os.Getenv("FOCUS_TOPIC"); topic != "" {
+		threshold := envFloat("FOCUS_THRESHOLD", 0.1)
+		orch.Crawler.Focus = crawler.NewTopicFocus(semantic.NewHashingEmbedder(128), topic, threshold)
+		logger.Info("focused_crawl_enabled", "topic", topic, "threshold", threshold)
+	}
 
 	seedDir := envOrDefault("SEED_DIR", filepath.Join("testdata", "pages"))
 	seedFiles := envOrDefault("SEED_FILES", "distributed-systems.html,resilient-search.html,ranking-ml.html,vector-search.html")
@@ -54,3 +73,13 @@ func splitAndTrim(csv string) []string {
 	}
 	return result
 }
+
+func envFloat(key string, fallback float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		f, err := strconv.ParseFloat(val, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return fallback
+}
@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/api"
+	"github.com/eshwanth/distributed-search-engine/internal/cluster"
 	"github.com/eshwanth/distributed-search-engine/internal/index"
 	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
 	"github.com/eshwanth/distributed-search-engine/internal/search"
@@ -25,44 +31,132 @@ func main() {
 	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
 	group := envOrDefault("SEARCH_GROUP", "search-api")
 
-	idx := index.NewInvertedIndex()
-	sem := semantic.NewIndex(semantic.Options{Dimension: 128, HyperplaneCount: 24})
+	analyzer, err := analysis.ByName(os.Getenv("INDEX_ANALYZER"), os.Getenv("INDEX_CJK_MODE"))
+	if err != nil {
+		logger.Error("search_api_config_invalid", err, "analyzer", os.Getenv("INDEX_ANALYZER"), "cjk_mode", os.Getenv("INDEX_CJK_MODE"))
+		os.Exit(2)
+	}
+	shardCount := envInt("INDEX_SHARDS", 1)
+	idx := index.NewShardedIndex(shardCount)
+	idx.SetAnalyzer(analyzer)
+	// A cluster node owns only NODE_SHARDS of the INDEX_SHARDS shards; by default it
+	// owns them all.
+	var owns func(docID string) bool
+	if owned := os.Getenv("NODE_SHARDS"); owned != "" {
+		assignment, err := cluster.ParseAssignment(shardCount, owned)
+		if err != nil {
+			logger.Error("search_api_config_invalid", err, "node_shards", owned)
+			os.Exit(2)
+		}
+		owns = assignment.Owns
+	}
+	sem := semantic.NewIndex(semantic.Options{Dimension: 128, HyperplaneCount: 24, Analyzer: analyzer})
+	images := index.NewInvertedIndex()
+	images.Analyzer = analyzer
 
-	snapshotPath := envOrDefault("SNAPSHOT_PATH", filepath.Join("data", "index.snapshot.json"))
-	if info, err := os.Stat(snapshotPath); err == nil && !info.IsDir() {
-		if docs, err := index.LoadSnapshot(snapshotPath); err == nil {
-			for _, doc := range docs {
-				idx.AddDocument(doc)
-				sem.AddDocument(doc)
-			}
-			logger.Info("snapshot_loaded", "path", snapshotPath, "documents", len(docs))
-		} else {
-			logger.Error("snapshot_load_failed", err, "path", snapshotPath)
+	snapshotPath := envOrDefault("SNAPSHOT_PATH", filepath.Join("data", "index.snapshot"))
+	start := time.Now()
+	snapshot, err := index.LoadSnapshot(snapshotPath)
+	switch {
+	case err == nil:
+		if snapshot.PrimaryErr != nil {
+			logger.Error("snapshot_fallback", snapshot.PrimaryErr, "path", snapshotPath, "loaded", snapshot.Path)
 		}
+		if err := restoreSnapshot(snapshot, idx, images, sem, owns); err != nil {
+			logger.Error("snapshot_restore_failed", err, "path", snapshot.Path)
+		}
+		logger.Info("snapshot_loaded", "path", snapshot.Path, "version", snapshot.Version, "documents", len(snapshot.Documents), "duration_ms", time.Since(start).Milliseconds())
+	case !errors.Is(err, fs.ErrNotExist):
+		logger.Error("snapshot_load_failed", err, "path", snapshotPath)
 	}
 
-	consumer := pipeline.NewKafkaConsumer(brokers, topic, group, logger)
+	// Every replica needs the whole document stream, so by default each reads all
+	// partitions itself, resuming from the offsets its snapshot records. Snapshots
+	// without them replay from shortly before they were written; replaying a document
+	// already in the snapshot applies the same version again.
+	var consumer *pipeline.KafkaConsumer
+	consumerMode := envOrDefault("SEARCH_CONSUMER_MODE", "broadcast")
+	switch consumerMode {
+	case "broadcast":
+		consumer = pipeline.NewBroadcastKafkaConsumer(brokers, topic, logger)
+		if snapshot != nil {
+			pos, ok, err := snapshot.Position()
+			if err != nil {
+				logger.Error("snapshot_position_invalid", err, "path", snapshot.Path)
+			}
+			if ok && pos.Topic == topic {
+				consumer.StartOffsets = pos.Offsets
+			} else if info, err := os.Stat(snapshot.Path); err == nil {
+				consumer.StartTime = info.ModTime().Add(-envDuration("SEARCH_REPLAY_WINDOW", 5*time.Minute))
+			}
+		}
+	case "group":
+		consumer = pipeline.NewKafkaConsumer(brokers, topic, group, logger)
+	default:
+		logger.Error("search_api_config_invalid", errors.New("unknown consumer mode"), "consumer_mode", consumerMode)
+		os.Exit(2)
+	}
+	// Replicas skip messages that fail for good unless given a dead-letter topic; the
+	// indexer dead-letters them once for the whole deployment.
+	consumer.DeadLetterTopic = os.Getenv("KAFKA_DEADLETTER_TOPIC")
+	consumer.MaxRetries = envInt("KAFKA_HANDLER_RETRIES", consumer.MaxRetries)
+	consumer.RetryBackoff = envDuration("KAFKA_RETRY_BACKOFF", consumer.RetryBackoff)
 	defer consumer.Close()
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	refreshInterval := envDuration("INDEX_REFRESH_INTERVAL", time.Second)
+	go idx.RunMerger(ctx, refreshInterval)
+	go images.RunMerger(ctx, refreshInterval)
+
 	updater := &pipeline.IndexUpdater{
 		Consumer:      consumer,
 		Index:         idx,
 		Semantic:      sem,
+		Images:        images,
+		Owns:          owns,
 		SnapshotPath:  "",
 		SnapshotEvery: 0,
 		Logger:        logger,
 	}
 
 	go func() {
+		// Without its updater the index would stop changing while the API kept serving
+		// it, so a consume failure restarts the process instead.
 		if err := updater.Run(ctx); err != nil {
 			logger.Error("search_index_updater_failed", err)
+			os.Exit(1)
 		}
 	}()
 
-	service := search.NewService(idx, sem)
+	service := search.NewService(nil, sem)
+	service.Shards = idx
+	service.Images = images
+	service.SynonymWeight = envFloat("SEARCH_SYNONYM_WEIGHT", service.SynonymWeight)
+	service.AutoCorrect = envBool("SEARCH_AUTOCORRECT", false)
+	service.MaxExpansions = envInt("SEARCH_MAX_EXPANSIONS", service.MaxExpansions)
+	service.QueryHistorySize = envInt("SEARCH_QUERY_HISTORY_SIZE", service.QueryHistorySize)
+	service.CompletionRebuildInterval = envDuration("SEARCH_COMPLETION_REBUILD_INTERVAL", service.CompletionRebuildInterval)
+	if synonymsPath := os.Getenv("SEARCH_SYNONYMS_PATH"); synonymsPath != "" {
+		go service.WatchSynonyms(ctx, synonymsPath, envDuration("SEARCH_SYNONYMS_RELOAD_INTERVAL", 30*time.Second), logger)
+	}
+	if peers := splitAndTrim(os.Getenv("CLUSTER_PEERS")); len(peers) > 0 {
+		service.Nodes = []search.ShardClient{cluster.Local{Service: service}}
+		for _, peer := range peers {
+			service.Nodes = append(service.Nodes, cluster.NewClient(peer))
+		}
+		service.NodeTimeout = envDuration("SEARCH_NODE_TIMEOUT", service.NodeTimeout)
+	}
+	if rpcAddr := os.Getenv("CLUSTER_RPC_ADDR"); rpcAddr != "" {
+		go func() {
+			logger.Info("shard_rpc_listening", "addr", rpcAddr)
+			rpc := &http.Server{Addr: rpcAddr, Handler: cluster.NewHandler(service, logger), ReadHeaderTimeout: 2 * time.Second}
+			if err := rpc.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("shard_rpc_failed", err)
+			}
+		}()
+	}
 	server := &api.Server{Search: service, Logger: logger}
 
 	addr := envOrDefault("SEARCH_HTTP_ADDR", ":8080")
@@ -74,12 +168,50 @@ func main() {
 		}
 	}()
 
-	logger.Info("search_api_listening", "addr", addr, "topic", topic, "group", group)
+	logger.Info("search_api_listening", "addr", addr, "topic", topic, "consumer_mode", consumerMode, "group", group, "shards", len(idx.Shards()), "nodes", len(service.Nodes))
 	if err := server.Start(addr); err != nil {
 		logger.Error("api_shutdown", err)
 	}
 }
 
+// restoreSnapshot installs prebuilt indexes from the snapshot. Older snapshots only
+// carry documents, and anything that fails to restore is rebuilt from them instead.
+// Semantic blobs from version 2 snapshots do not record their analyzer and are rebuilt.
+// A cluster node, with owns set, re-indexes only its own pages from the snapshot.
+func restoreSnapshot(snapshot *index.LoadedSnapshot, idx *index.ShardedIndex, images *index.InvertedIndex, sem *semantic.Index, owns func(docID string) bool) error {
+	var pagesErr error
+	pagesRestored := false
+	if owns == nil {
+		pagesRestored, pagesErr = snapshot.RestoreShardedIndex(index.SnapshotPages, idx)
+	}
+	imagesRestored, imagesErr := snapshot.RestoreIndex(index.SnapshotImages, images)
+	var semanticErr error
+	semanticRestored := false
+	if data, ok := snapshot.Blob(index.SnapshotSemantic); ok && snapshot.Version >= 3 && owns == nil {
+		semanticErr = sem.UnmarshalBinary(data)
+		semanticRestored = semanticErr == nil
+	}
+
+	for _, doc := range snapshot.Documents {
+		if doc.IsImage() {
+			if !imagesRestored {
+				images.AddDocument(doc)
+			}
+			continue
+		}
+		if owns != nil && !owns(doc.ID) {
+			continue
+		}
+		if !pagesRestored {
+			idx.AddDocument(doc)
+		}
+		if !semanticRestored {
+			sem.AddDocument(doc)
+		}
+	}
+	return errors.Join(pagesErr, imagesErr, semanticErr)
+}
+
 func envOrDefault(key, fallback string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -98,3 +230,43 @@ func splitAndTrim(csv string) []string {
 	}
 	return result
 }
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		dur, err := time.ParseDuration(val)
+		if err == nil {
+			return dur
+		}
+	}
+	return fallback
+}
+
+func envFloat(key string, fallback float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		f, err := strconv.ParseFloat(val, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func envBool(key string, fallback bool) bool {
+	if val := os.Getenv(key); val != "" {
+		b, err := strconv.ParseBool(val)
+		if err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		n, err := strconv.Atoi(val)
+		if err == nil {
+			return n
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// tombstone publishes delete markers for documents indexed by mistake. Arguments are
+// document IDs or page URLs, which are mapped to the ID the crawler assigns them.
+func main() {
+	logger := telemetry.NewStdLogger()
+
+	targets := os.Args[1:]
+	if len(targets) == 0 {
+		logger.Error("tombstone_failed", os.ErrInvalid, "reason", "pass document IDs or URLs as arguments")
+		os.Exit(2)
+	}
+
+	brokers := splitAndTrim(envOrDefault("KAFKA_BROKERS", "localhost:9092"))
+	topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
+	sink := pipeline.NewKafkaSink(brokers, topic, logger)
+	sink.Source = "tombstone"
+	defer sink.Close()
+
+	for _, target := range targets {
+		id := target
+		if strings.Contains(target, "://") {
+			id = crawler.DocumentID(target)
+		}
+		sink.Consume(docs.Tombstone(id))
+		logger.Info("tombstone_published", "doc_id", id, "target", target, "topic", topic)
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
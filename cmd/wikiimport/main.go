@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
+	"github.com/eshwanth/distributed-search-engine/internal/importer"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+func main() {
+	telemetry.RegisterMetrics()
+	logger := telemetry.NewStdLogger()
+
+	dumpPath := os.Getenv("WIKI_DUMP")
+	if dumpPath == "" && len(os.Args) > 1 {
+		dumpPath = os.Args[1]
+	}
+	if dumpPath == "" {
+		logger.Error("wiki_import_failed", os.ErrInvalid, "reason", "set WIKI_DUMP or pass the dump path as an argument")
+		os.Exit(2)
+	}
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		logger.Error("wiki_dump_open_failed", err, "path", dumpPath)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	opts := importer.MediaWikiOptions{
+		BaseURL:       envOrDefault("WIKI_BASE_URL", "https://en.wikipedia.org/wiki/"),
+		Namespaces:    splitInts(envOrDefault("WIKI_NAMESPACES", "0")),
+		MaxPages:      envInt("WIKI_MAX_PAGES", 0),
+		ProgressEvery: envInt("WIKI_PROGRESS_EVERY", 1000),
+		Logger:        logger,
+	}
+
+	// IMPORT_SINK=index builds an in-memory index and writes a snapshot instead of
+	// publishing to Kafka, which is handy for offline load tests.
+	var sink crawler.DocumentSink
+	var idx *index.InvertedIndex
+	switch sinkKind := envOrDefault("IMPORT_SINK", "kafka"); sinkKind {
+	case "index":
+		analyzer, err := analysis.ByName(os.Getenv("INDEX_ANALYZER"), os.Getenv("INDEX_CJK_MODE"))
+		if err != nil {
+			logger.Error("wiki_import_failed", err, "analyzer", os.Getenv("INDEX_ANALYZER"), "cjk_mode", os.Getenv("INDEX_CJK_MODE"))
+			os.Exit(2)
+		}
+		idx = index.NewInvertedIndex()
+		idx.Analyzer = analyzer
+		sink = pipeline.NewIndexSink(idx)
+	case "kafka":
+		brokers := splitAndTrim(envOrDefault("KAFKA_BROKERS", "localhost:9092"))
+		topic := envOrDefault("KAFKA_DOCUMENT_TOPIC", "documents")
+		kafkaSink := pipeline.NewKafkaSink(brokers, topic, logger)
+		kafkaSink.Source = "wikiimport"
+		kafkaSink.Codec = envOrDefault("KAFKA_EVENT_CODEC", pipeline.CodecJSON)
+		sink = kafkaSink
+	default:
+		logger.Error("wiki_import_failed", os.ErrInvalid, "reason", "unknown IMPORT_SINK", "sink", sinkKind)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	logger.Info("wiki_import_start", "path", dumpPath)
+	stats, err := importer.ImportMediaWiki(ctx, file, sink, opts)
+	sink.Close()
+	if err != nil {
+		logger.Error("wiki_import_failed", err, "path", dumpPath, "published", stats.Published)
+	}
+	logger.Info("wiki_import_complete", "read", stats.Read, "published", stats.Published, "skipped", stats.Skipped, "duration_ms", time.Since(start).Milliseconds())
+
+	if idx != nil {
+		snapshotPath := envOrDefault("SNAPSHOT_PATH", filepath.Join("data", "index.snapshot"))
+		if err := index.WriteSnapshot(idx, snapshotPath); err != nil {
+			logger.Error("write_snapshot_failed", err, "path", snapshotPath)
+			os.Exit(1)
+		}
+		logger.Info("snapshot_written", "path", snapshotPath, "documents", idx.DocumentCount())
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		n, err := strconv.Atoi(val)
+		if err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+func splitInts(csv string) []int {
+	var result []int
+	for _, part := range splitAndTrim(csv) {
+		n, err := strconv.Atoi(part)
+		if err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -6,6 +6,7 @@ require (
 	github.com/prometheus/client_golang v1.19.0
 	github.com/segmentio/kafka-go v0.4.45
 	golang.org/x/net v0.44.0
+	golang.org/x/text v0.29.0
 )
 
 require (
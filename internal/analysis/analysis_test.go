@@ -0,0 +1,112 @@
+package analysis_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+)
+
+func TestStandardKeepsUnicodeWords(t *testing.T) {
+	got := analysis.Standard().Analyze("Café Société: Москва, ΣΟΦΊΑ and Straße 42!")
+	want := []string{"cafe", "societe", "москва", "σοφια", "and", "strasse", "42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := analysis.Standard().Analyze(" -- "); got != nil {
+		t.Fatalf("expected no tokens, got %v", got)
+	}
+}
+
+func TestEnglishStemsAndDropsStopwords(t *testing.T) {
+	got := analysis.English().Analyze("The runners are running to the connected connections")
+	want := []string{"runner", "run", "connect", "connect"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRemovedStopwordsKeepTheirPositions(t *testing.T) {
+	terms, positions := analysis.English().AnalyzePositions("The state of the art")
+	if !reflect.DeepEqual(terms, []string{"state", "art"}) || !reflect.DeepEqual(positions, []int{1, 4}) {
+		t.Fatalf("expected state and art at 1 and 4, got %v at %v", terms, positions)
+	}
+	if _, positions := analysis.Standard().AnalyzePositions("state of the art"); !reflect.DeepEqual(positions, []int{0, 1, 2, 3}) {
+		t.Fatalf("expected consecutive positions without stopwords, got %v", positions)
+	}
+}
+
+func TestPorterStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses":        "caress",
+		"ponies":          "poni",
+		"cats":            "cat",
+		"agreed":          "agre",
+		"plastered":       "plaster",
+		"motoring":        "motor",
+		"hopping":         "hop",
+		"filing":          "file",
+		"happy":           "happi",
+		"relational":      "relat",
+		"generalizations": "gener",
+		"electrical":      "electr",
+		"adjustable":      "adjust",
+		"controlling":     "control",
+		"is":              "is",
+		"naïve":           "naïve",
+	}
+	for word, want := range cases {
+		if got := analysis.PorterStem(word); got != want {
+			t.Errorf("PorterStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestCJKRunsBecomeBigrams(t *testing.T) {
+	got := analysis.Standard().Analyze("Kafka入門：東京大学のコーヒー, 한국어 OK")
+	want := []string{"kafka", "入門", "東京", "京大", "大学", "学の", "のコ", "コー", "ーヒ", "ヒー", "한국", "국어", "ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := analysis.Standard().Analyze("ガ"); !reflect.DeepEqual(got, []string{"ガ"}) {
+		t.Fatalf("expected kana voicing to survive accent folding, got %v", got)
+	}
+}
+
+func TestCJKDictionarySegmentation(t *testing.T) {
+	analyzer, err := analysis.ByName(analysis.NameStandard, analysis.CJKDictionary)
+	if err != nil {
+		t.Fatalf("ByName: %v", err)
+	}
+	got := analyzer.Analyze("我们使用分布式搜索引擎和Raft")
+	want := []string{"我们", "使用", "分布式", "搜索引擎", "和", "raft"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	dict := analysis.NewDictionary([]string{"検索"})
+	if got := dict.Segment([]rune("全文検索入門")); !reflect.DeepEqual(got, []string{"全文", "検索", "入門"}) {
+		t.Fatalf("expected unknown stretches as bigrams, got %v", got)
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"", analysis.NameStandard, analysis.NameEnglish} {
+		for _, mode := range []string{"", analysis.CJKBigram, analysis.CJKDictionary} {
+			if _, err := analysis.ByName(name, mode); err != nil {
+				t.Fatalf("ByName(%q, %q): %v", name, mode, err)
+			}
+		}
+	}
+	bigram, _ := analysis.ByName("", "")
+	dictionary, _ := analysis.ByName("", analysis.CJKDictionary)
+	if bigram.Name() != analysis.Standard().Name() || bigram.Name() == dictionary.Name() {
+		t.Fatalf("expected names to identify the CJK mode, got %q and %q", bigram.Name(), dictionary.Name())
+	}
+	if _, err := analysis.ByName("klingon", ""); err == nil {
+		t.Fatalf("expected unknown analyzer to fail")
+	}
+	if _, err := analysis.ByName("", "trigram"); err == nil {
+		t.Fatalf("expected unknown CJK mode to fail")
+	}
+}
@@ -0,0 +1,180 @@
+// Package analysis turns text into the terms stored in and looked up from indexes.
+//
+// An Analyzer runs char filters over the raw text, splits it into tokens, and passes
+// the tokens through token filters. Indexing and querying must use the same analyzer,
+// otherwise query terms will not match the indexed ones.
+package analysis
+
+import "fmt"
+
+// Names of the built-in analyzers.
+const (
+	NameStandard = "standard"
+	NameEnglish  = "english"
+)
+
+// Analyzer converts text into index terms.
+type Analyzer interface {
+	// Name identifies the analyzer so persisted terms can be checked for compatibility.
+	Name() string
+	// Analyze returns the terms of text in order of appearance.
+	Analyze(text string) []string
+	// AnalyzePositions returns the terms of text with the position of each. Tokens that
+	// filters removed, such as stopwords, still take up a position, so the terms on
+	// either side of them are not adjacent.
+	AnalyzePositions(text string) (terms []string, positions []int)
+}
+
+// CharFilter rewrites text before it is tokenized.
+type CharFilter interface {
+	FilterText(text string) string
+}
+
+// Tokenizer splits text into tokens.
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// TokenFilter transforms, removes, or adds tokens. Filters may reuse the input slice.
+// A filter that removes a token but wants to keep its position replaces it with an
+// empty token, which later filters pass through and the Pipeline drops from the terms.
+type TokenFilter interface {
+	FilterTokens(tokens []string) []string
+}
+
+// CharFilterFunc adapts a function to CharFilter.
+type CharFilterFunc func(text string) string
+
+// FilterText calls f.
+func (f CharFilterFunc) FilterText(text string) string { return f(text) }
+
+// TokenFilterFunc adapts a function to TokenFilter.
+type TokenFilterFunc func(tokens []string) []string
+
+// FilterTokens calls f.
+func (f TokenFilterFunc) FilterTokens(tokens []string) []string { return f(tokens) }
+
+// Pipeline is an Analyzer assembled from char filters, a tokenizer, and token filters.
+type Pipeline struct {
+	// ID is returned by Name. Changing any stage should change the ID.
+	ID           string
+	CharFilters  []CharFilter
+	Tokenizer    Tokenizer
+	TokenFilters []TokenFilter
+}
+
+// Name returns the pipeline ID.
+func (p *Pipeline) Name() string {
+	return p.ID
+}
+
+// Analyze runs every stage of the pipeline over text.
+func (p *Pipeline) Analyze(text string) []string {
+	tokens := p.tokens(text)
+	terms := tokens[:0]
+	for _, token := range tokens {
+		if token != "" {
+			terms = append(terms, token)
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return terms
+}
+
+// AnalyzePositions runs every stage of the pipeline over text and numbers the terms by
+// their token position, counting the empty tokens left by removed words.
+func (p *Pipeline) AnalyzePositions(text string) ([]string, []int) {
+	tokens := p.tokens(text)
+	var terms []string
+	var positions []int
+	for pos, token := range tokens {
+		if token != "" {
+			terms = append(terms, token)
+			positions = append(positions, pos)
+		}
+	}
+	return terms, positions
+}
+
+// tokens returns the filtered tokens of text, including empty tokens.
+func (p *Pipeline) tokens(text string) []string {
+	for _, filter := range p.CharFilters {
+		text = filter.FilterText(text)
+	}
+	tokens := p.Tokenizer.Tokenize(text)
+	for _, filter := range p.TokenFilters {
+		if len(tokens) == 0 {
+			return nil
+		}
+		tokens = filter.FilterTokens(tokens)
+	}
+	return tokens
+}
+
+// CJK segmentation modes accepted by ByName.
+const (
+	// CJKBigram indexes Chinese, Japanese, and Korean runs as overlapping bigrams, which
+	// needs no dictionary and matches any substring of two or more characters.
+	CJKBigram = "bigram"
+	// CJKDictionary segments CJK runs into words from the bundled word list and falls
+	// back to bigrams for stretches the list does not cover.
+	CJKDictionary = "dictionary"
+)
+
+// Standard returns the default analyzer: Unicode word segmentation followed by case
+// and accent folding, with CJK runs split into bigrams. It keeps every word, so it
+// suits text in any language.
+func Standard() Analyzer {
+	return newPipeline(NameStandard, CJKBigram, CJKBigramFilter{})
+}
+
+// English extends Standard with English stopword removal and Porter stemming, so
+// "running" and "runs" both match "run".
+func English() Analyzer {
+	return newPipeline(NameEnglish, CJKBigram, CJKBigramFilter{}, NewStopFilter(EnglishStopwords), PorterStemFilter{})
+}
+
+// stopwordGaps marks the IDs of analyzers whose stopwords leave position gaps, so
+// indexes built before the gaps existed are rebuilt rather than restored.
+const stopwordGaps = "+stop-gaps"
+
+// newPipeline builds a built-in analyzer. The ID records the CJK mode because it
+// changes the indexed terms.
+func newPipeline(name, cjkMode string, cjk TokenFilter, extra ...TokenFilter) *Pipeline {
+	filters := append([]TokenFilter{LowercaseFilter{}, cjk, AccentFilter{}}, extra...)
+	id := name + "+cjk-" + cjkMode
+	for _, filter := range extra {
+		if _, ok := filter.(StopFilter); ok {
+			id += stopwordGaps
+		}
+	}
+	return &Pipeline{
+		ID:           id,
+		Tokenizer:    UnicodeTokenizer{},
+		TokenFilters: filters,
+	}
+}
+
+// ByName returns a built-in analyzer with the given CJK segmentation mode. Empty
+// values select Standard and CJKBigram.
+func ByName(name, cjkMode string) (Analyzer, error) {
+	var cjk TokenFilter
+	switch cjkMode {
+	case "", CJKBigram:
+		cjkMode, cjk = CJKBigram, CJKBigramFilter{}
+	case CJKDictionary:
+		cjk = CJKDictionaryFilter{Dictionary: DefaultCJKDictionary()}
+	default:
+		return nil, fmt.Errorf("unknown CJK mode %q", cjkMode)
+	}
+	switch name {
+	case "", NameStandard:
+		return newPipeline(NameStandard, cjkMode, cjk), nil
+	case NameEnglish:
+		return newPipeline(NameEnglish, cjkMode, cjk, NewStopFilter(EnglishStopwords), PorterStemFilter{}), nil
+	default:
+		return nil, fmt.Errorf("unknown analyzer %q", name)
+	}
+}
@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// isCJK reports whether r belongs to a script written without spaces between words,
+// including the marks that only occur inside such runs.
+func isCJK(r rune) bool {
+	if r < 0x1100 {
+		return false
+	}
+	switch r {
+	case 'ー', '々', '〆':
+		return true
+	}
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isCJKToken(token string) bool {
+	r, _ := utf8.DecodeRuneInString(token)
+	return isCJK(r)
+}
+
+// CJKBigramFilter replaces each CJK token with its overlapping character bigrams, so
+// "東京大学" becomes "東京", "京大", "大学". A single character stays a unigram. Queries
+// analyzed the same way match any run of two or more characters.
+type CJKBigramFilter struct{}
+
+// FilterTokens splits CJK tokens into bigrams and keeps other tokens unchanged.
+func (CJKBigramFilter) FilterTokens(tokens []string) []string {
+	return splitCJK(tokens, bigrams)
+}
+
+// CJKDictionaryFilter segments CJK tokens into the longest words found in Dictionary,
+// scanning left to right. Stretches with no dictionary word become bigrams.
+type CJKDictionaryFilter struct {
+	Dictionary *Dictionary
+}
+
+// FilterTokens segments CJK tokens and keeps other tokens unchanged.
+func (f CJKDictionaryFilter) FilterTokens(tokens []string) []string {
+	return splitCJK(tokens, f.Dictionary.Segment)
+}
+
+func splitCJK(tokens []string, split func(runes []rune) []string) []string {
+	var out []string
+	for i, token := range tokens {
+		if !isCJKToken(token) {
+			if out != nil {
+				out = append(out, token)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]string, 0, len(tokens)+8), tokens[:i]...)
+		}
+		out = append(out, split([]rune(norm.NFC.String(token)))...)
+	}
+	if out == nil {
+		return tokens
+	}
+	return out
+}
+
+func bigrams(runes []rune) []string {
+	if len(runes) < 2 {
+		return []string{string(runes)}
+	}
+	out := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		out = append(out, string(runes[i:i+2]))
+	}
+	return out
+}
+
+// Dictionary is a word list used for CJK segmentation.
+type Dictionary struct {
+	words  map[string]struct{}
+	maxLen int
+}
+
+// NewDictionary builds a dictionary from words; blank entries are ignored.
+func NewDictionary(words []string) *Dictionary {
+	d := &Dictionary{words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		word = norm.NFC.String(strings.TrimSpace(word))
+		if word == "" {
+			continue
+		}
+		d.words[word] = struct{}{}
+		d.maxLen = max(d.maxLen, utf8.RuneCountInString(word))
+	}
+	return d
+}
+
+// LoadDictionary reads one word per line. Lines starting with # are comments.
+func LoadDictionary(r io.Reader) (*Dictionary, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewDictionary(words), nil
+}
+
+//go:embed cjk_words.txt
+var bundledCJKWords string
+
+var (
+	defaultCJKDictionary     *Dictionary
+	defaultCJKDictionaryOnce sync.Once
+)
+
+// DefaultCJKDictionary returns the bundled word list of common Chinese and Japanese
+// words.
+func DefaultCJKDictionary() *Dictionary {
+	defaultCJKDictionaryOnce.Do(func() {
+		// Reading from a string cannot fail.
+		defaultCJKDictionary, _ = LoadDictionary(strings.NewReader(bundledCJKWords))
+	})
+	return defaultCJKDictionary
+}
+
+// Segment splits a CJK run into dictionary words by forward maximum matching. Runs of
+// characters not covered by any word are emitted as bigrams.
+func (d *Dictionary) Segment(runes []rune) []string {
+	var out []string
+	unknown := -1
+	flush := func(end int) {
+		if unknown >= 0 {
+			out = append(out, bigrams(runes[unknown:end])...)
+			unknown = -1
+		}
+	}
+	for i := 0; i < len(runes); {
+		n := d.longestMatch(runes[i:])
+		if n == 0 {
+			if unknown < 0 {
+				unknown = i
+			}
+			i++
+			continue
+		}
+		flush(i)
+		out = append(out, string(runes[i:i+n]))
+		i += n
+	}
+	flush(len(runes))
+	return out
+}
+
+func (d *Dictionary) longestMatch(runes []rune) int {
+	for n := min(d.maxLen, len(runes)); n > 0; n-- {
+		if _, ok := d.words[string(runes[:n])]; ok {
+			return n
+		}
+	}
+	return 0
+}
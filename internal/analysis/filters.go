@@ -0,0 +1,163 @@
+package analysis
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeTokenizer splits text into runs of letters, marks, and digits, so words in
+// any script are kept whole. Everything else separates tokens. A change between CJK
+// and other scripts also starts a new token, so "Kafka入門" yields "Kafka" and "入門"
+// and CJK runs can be segmented by a CJK filter.
+type UnicodeTokenizer struct{}
+
+// Tokenize returns the words of text.
+func (t UnicodeTokenizer) Tokenize(text string) []string {
+	spans := t.Spans(text)
+	if len(spans) == 0 {
+		return nil
+	}
+	tokens := make([]string, len(spans))
+	for i, span := range spans {
+		tokens[i] = text[span[0]:span[1]]
+	}
+	return tokens
+}
+
+// Spans returns the byte offsets [start, end) of the words of text, which lets callers
+// such as snippet builders map terms back to the original text.
+func (UnicodeTokenizer) Spans(text string) [][2]int {
+	var spans [][2]int
+	start, cjk := -1, false
+	for i, r := range text {
+		if !isWordRune(r) {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		isMark := unicode.In(r, unicode.Mn, unicode.Mc)
+		if start >= 0 && !isMark && isCJK(r) != cjk {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+		if start < 0 {
+			start, cjk = i, isCJK(r)
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+// LowercaseFilter applies Unicode case folding, which also maps forms such as "ß" and
+// final sigma that plain lowercasing leaves distinct.
+type LowercaseFilter struct{}
+
+// FilterTokens folds the case of every token.
+func (LowercaseFilter) FilterTokens(tokens []string) []string {
+	folder := cases.Fold()
+	for i, token := range tokens {
+		if isLowerASCII(token) {
+			continue
+		}
+		tokens[i] = folder.String(token)
+	}
+	return tokens
+}
+
+func isLowerASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf || ('A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// AccentFilter removes combining marks after canonical decomposition, so "café" and
+// "cafe" produce the same term. Tokens made only of marks are kept as they were, and
+// CJK tokens are left alone because kana voicing marks distinguish words.
+type AccentFilter struct{}
+
+// FilterTokens strips accents from every token.
+func (AccentFilter) FilterTokens(tokens []string) []string {
+	for i, token := range tokens {
+		if isASCII(token) || isCJKToken(token) {
+			continue
+		}
+		decomposed := norm.NFD.String(token)
+		stripped := make([]rune, 0, len(decomposed))
+		for _, r := range decomposed {
+			if !unicode.Is(unicode.Mn, r) {
+				stripped = append(stripped, r)
+			}
+		}
+		if len(stripped) > 0 {
+			tokens[i] = norm.NFC.String(string(stripped))
+		}
+	}
+	return tokens
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// StopFilter removes tokens found in a stopword set, leaving empty tokens in their
+// place so that positions still count them.
+type StopFilter struct {
+	Words map[string]struct{}
+}
+
+// NewStopFilter builds a StopFilter from a word list.
+func NewStopFilter(words []string) StopFilter {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return StopFilter{Words: set}
+}
+
+// FilterTokens empties stopwords.
+func (f StopFilter) FilterTokens(tokens []string) []string {
+	for i, token := range tokens {
+		if _, stop := f.Words[token]; stop {
+			tokens[i] = ""
+		}
+	}
+	return tokens
+}
+
+// PorterStemFilter reduces English tokens to their Porter stems. It expects lowercase
+// input and leaves tokens containing non-ASCII letters unchanged.
+type PorterStemFilter struct{}
+
+// FilterTokens stems every token.
+func (PorterStemFilter) FilterTokens(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = PorterStem(token)
+	}
+	return tokens
+}
+
+// EnglishStopwords is the stopword list used by English.
+var EnglishStopwords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into",
+	"is", "it", "no", "not", "of", "on", "or", "such", "that", "the", "their", "then",
+	"there", "these", "they", "this", "to", "was", "will", "with",
+}
@@ -19,6 +19,9 @@ type Server struct {
 func (s *Server) Start(addr string) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/v2/search", s.handleSearchV2)
+	mux.HandleFunc("/search/images", s.handleImageSearch)
+	mux.HandleFunc("/suggest", s.handleSuggest)
 
 	srv := &http.Server{
 		Addr:              addr,
@@ -31,6 +34,9 @@ func (s *Server) Start(addr string) error {
 	return srv.ListenAndServe()
 }
 
+// handleSearch responds with a bare JSON array of results, the shape /search has always
+// had. Spelling suggestions, autocorrection, and partial cluster results are only
+// reported by /v2/search.
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	query := r.URL.Query().Get("q")
@@ -45,6 +51,71 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(results) > 0 {
+		s.Search.RecordQuery(query)
+	}
 	s.Logger.Info("search", "q", query, "count", len(results), "latency_ms", time.Since(start).Milliseconds())
 	telemetry.ObserveSearch("ok", time.Since(start))
 }
+
+// handleSearchV2 responds with a search.Response object carrying the results together
+// with any spelling suggestion and the corrected and partial flags.
+func (s *Server) handleSearchV2(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	query := r.URL.Query().Get("q")
+	limit := 10
+	resp := s.Search.SearchWithSuggestions(query, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.Logger.Error("encode_response_failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		telemetry.ObserveSearch("error", time.Since(start))
+		return
+	}
+
+	if len(resp.Results) > 0 {
+		if resp.Corrected {
+			s.Search.RecordQuery(resp.Suggestion)
+		} else {
+			s.Search.RecordQuery(query)
+		}
+	}
+	s.Logger.Info("search_v2", "q", query, "count", len(resp.Results), "suggestion", resp.Suggestion, "corrected", resp.Corrected, "partial", resp.Partial, "latency_ms", time.Since(start).Milliseconds())
+	telemetry.ObserveSearch("ok", time.Since(start))
+}
+
+func (s *Server) handleImageSearch(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	query := r.URL.Query().Get("q")
+	limit := 10
+	results := s.Search.SearchImages(query, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		s.Logger.Error("encode_response_failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		telemetry.ObserveSearch("error", time.Since(start))
+		return
+	}
+
+	s.Logger.Info("image_search", "q", query, "count", len(results), "latency_ms", time.Since(start).Milliseconds())
+	telemetry.ObserveSearch("ok", time.Since(start))
+}
+
+func (s *Server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	prefix := r.URL.Query().Get("q")
+	completions := s.Search.Complete(prefix, search.MaxCompletions)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Suggestions []search.Completion `json:"suggestions"`
+	}{Suggestions: completions}); err != nil {
+		s.Logger.Error("encode_response_failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	s.Logger.Info("suggest", "q", prefix, "count", len(completions), "latency_ms", time.Since(start).Milliseconds())
+}
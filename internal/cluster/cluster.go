@@ -0,0 +1,168 @@
+// Package cluster connects search nodes that each own a subset of the index shards.
+// Nodes serve a shard-search RPC over HTTP, and a coordinator fans queries out to
+// them through search.Service.Nodes.
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// RPC paths served by NewHandler.
+const (
+	PreparePath = "/internal/shards/prepare"
+	SearchPath  = "/internal/shards/search"
+)
+
+// Assignment is the set of shards a node owns out of the cluster's shard count.
+type Assignment struct {
+	Shards int
+	owned  map[int]bool
+}
+
+// ParseAssignment parses a comma-separated list of shard numbers owned out of total.
+// An empty list owns every shard.
+func ParseAssignment(total int, owned string) (Assignment, error) {
+	if total < 1 {
+		return Assignment{}, fmt.Errorf("cluster shard count %d must be positive", total)
+	}
+	a := Assignment{Shards: total, owned: make(map[int]bool)}
+	for _, part := range strings.Split(owned, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		shard, err := strconv.Atoi(part)
+		if err != nil || shard < 0 || shard >= total {
+			return Assignment{}, fmt.Errorf("invalid shard %q for %d shards", part, total)
+		}
+		a.owned[shard] = true
+	}
+	if len(a.owned) == 0 {
+		for shard := 0; shard < total; shard++ {
+			a.owned[shard] = true
+		}
+	}
+	return a, nil
+}
+
+// Owns reports whether the document belongs to one of the node's shards.
+func (a Assignment) Owns(docID string) bool {
+	return a.owned[index.ShardOf(docID, a.Shards)]
+}
+
+// NewHandler serves the shard-search RPC for the local shards of svc.
+func NewHandler(svc *search.Service, logger telemetry.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+PreparePath, func(w http.ResponseWriter, r *http.Request) {
+		var req search.PrepareRequest
+		if !decodeRequest(w, r, &req, logger) {
+			return
+		}
+		writeResponse(w, svc.PrepareShards(req), logger)
+	})
+	mux.HandleFunc("POST "+SearchPath, func(w http.ResponseWriter, r *http.Request) {
+		var req search.ShardSearchRequest
+		if !decodeRequest(w, r, &req, logger) {
+			return
+		}
+		writeResponse(w, svc.SearchShards(req), logger)
+	})
+	return mux
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any, logger telemetry.Logger) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		logger.Error("shard_rpc_bad_request", err, "path", r.URL.Path)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, v any, logger telemetry.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("encode_response_failed", err)
+	}
+}
+
+// Client calls the shard-search RPC of a remote node.
+type Client struct {
+	// BaseURL is the node's RPC address, such as http://search-1:8081.
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for the node at baseURL. Request deadlines come from the
+// contexts passed by the coordinator.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTP: &http.Client{}}
+}
+
+// Prepare implements search.ShardClient.
+func (c *Client) Prepare(ctx context.Context, req search.PrepareRequest) (search.PrepareResponse, error) {
+	var resp search.PrepareResponse
+	err := c.call(ctx, PreparePath, req, &resp)
+	return resp, err
+}
+
+// Search implements search.ShardClient.
+func (c *Client) Search(ctx context.Context, req search.ShardSearchRequest) ([]search.Result, error) {
+	var results []search.Result
+	err := c.call(ctx, SearchPath, req, &results)
+	return results, err
+}
+
+func (c *Client) call(ctx context.Context, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 512))
+		return fmt.Errorf("%s%s: %s: %s", c.BaseURL, path, httpResp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// Local calls the shards of a service in the same process, so a coordinator that is
+// also a node does not go through HTTP to reach itself.
+type Local struct {
+	Service *search.Service
+}
+
+// Prepare implements search.ShardClient.
+func (l Local) Prepare(ctx context.Context, req search.PrepareRequest) (search.PrepareResponse, error) {
+	return l.Service.PrepareShards(req), ctx.Err()
+}
+
+// Search implements search.ShardClient.
+func (l Local) Search(ctx context.Context, req search.ShardSearchRequest) ([]search.Result, error) {
+	return l.Service.SearchShards(req), ctx.Err()
+}
+
+var (
+	_ search.ShardClient = (*Client)(nil)
+	_ search.ShardClient = Local{}
+)
@@ -0,0 +1,140 @@
+package cluster_test
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/cluster"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+
+const clusterShards = 6
+
+func corpus() []*docs.Document {
+	topics := []string{"kafka consumer groups", "raft leader election", "kafka log compaction", "vector search with kafka", "distributed tracing"}
+	var out []*docs.Document
+	for i := 0; i < 60; i++ {
+		out = append(out, &docs.Document{
+			ID:      fmt.Sprintf("doc-%02d", i),
+			Title:   topics[i%len(topics)],
+			Content: strings.Repeat(topics[(i+2)%len(topics)]+" ", 1+i%4),
+		})
+	}
+	return out
+}
+
+// startNode serves the shards listed in owned over HTTP on localhost, indexing only
+// the documents it owns.
+func startNode(t *testing.T, owned string, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	assignment, err := cluster.ParseAssignment(clusterShards, owned)
+	if err != nil {
+		t.Fatalf("parse assignment: %v", err)
+	}
+	shards := index.NewShardedIndex(clusterShards)
+	for _, doc := range corpus() {
+		if assignment.Owns(doc.ID) {
+			shards.AddDocument(doc)
+		}
+	}
+	svc := search.NewService(nil, nil)
+	svc.Shards = shards
+	var handler http.Handler = cluster.NewHandler(svc, nopLogger{})
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func coordinator(servers ...*httptest.Server) *search.Service {
+	svc := search.NewService(nil, nil)
+	svc.Shards = index.NewShardedIndex(1)
+	for _, server := range servers {
+		svc.Nodes = append(svc.Nodes, cluster.NewClient(server.URL))
+	}
+	return svc
+}
+
+func TestClusterSearchMatchesSingleNode(t *testing.T) {
+	single := index.NewInvertedIndex()
+	for _, doc := range corpus() {
+		single.AddDocument(doc)
+	}
+	want := search.NewService(single, nil)
+	got := coordinator(startNode(t, "0,1", nil), startNode(t, "2,3", nil), startNode(t, "4,5", nil))
+
+	for _, query := range []string{"kafka", "title:raft election", `"log compaction"`, "kafak~1 vector", "distrib*"} {
+		expected := want.Search(query, 10)
+		resp := got.SearchWithSuggestions(query, 10)
+		if resp.Partial {
+			t.Fatalf("%q: unexpected partial response", query)
+		}
+		if len(resp.Results) != len(expected) || len(expected) == 0 {
+			t.Fatalf("%q: expected %d results, got %d", query, len(expected), len(resp.Results))
+		}
+		for i := range expected {
+			if resp.Results[i].DocID != expected[i].DocID || math.Abs(resp.Results[i].Score-expected[i].Score) > 1e-9 {
+				t.Fatalf("%q: expected %+v, got %+v", query, expected, resp.Results)
+			}
+		}
+	}
+}
+
+func TestClusterReturnsPartialResults(t *testing.T) {
+	healthy := startNode(t, "0,1,2", nil)
+	down := startNode(t, "3,4,5", nil)
+	down.Close()
+	slow := startNode(t, "3,4,5", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == cluster.SearchPath {
+				time.Sleep(500 * time.Millisecond)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	for name, other := range map[string]*httptest.Server{"failed": down, "slow": slow} {
+		svc := coordinator(healthy, other)
+		svc.NodeTimeout = 100 * time.Millisecond
+		resp := svc.SearchWithSuggestions("kafka", 50)
+		if !resp.Partial || len(resp.Results) == 0 {
+			t.Fatalf("%s node: expected partial results, got %+v", name, resp)
+		}
+		assignment, _ := cluster.ParseAssignment(clusterShards, "0,1,2")
+		for _, result := range resp.Results {
+			if !assignment.Owns(result.DocID) {
+				t.Fatalf("%s node: unexpected result %s from an unavailable node", name, result.DocID)
+			}
+		}
+	}
+
+	if resp := coordinator(down).SearchWithSuggestions("kafka", 10); !resp.Partial || len(resp.Results) != 0 {
+		t.Fatalf("expected an empty partial response when every node is down, got %+v", resp)
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	all, err := cluster.ParseAssignment(3, "")
+	if err != nil || !all.Owns("anything") {
+		t.Fatalf("expected an empty list to own every shard, got %v", err)
+	}
+	for _, bad := range []string{"3", "-1", "x"} {
+		if _, err := cluster.ParseAssignment(3, bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
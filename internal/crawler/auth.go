@@ -0,0 +1,283 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Secret references a credential kept outside the configuration, in an environment
+// variable or a file. The value is resolved on use and never printed.
+type Secret struct {
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Resolve reads the secret value. Errors name the source but never include the value.
+func (s Secret) Resolve() (string, error) {
+	switch {
+	case s.Env != "":
+		val, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("credential env %s is not set", s.Env)
+		}
+		return val, nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("credential file %s: %w", s.File, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", fmt.Errorf("credential has no env or file source")
+}
+
+// String keeps secrets out of logs and formatted errors.
+func (s Secret) String() string {
+	return "[redacted]"
+}
+
+// BasicAuth holds HTTP basic credentials.
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password Secret `json:"password"`
+}
+
+// FormLogin describes a login form submitted once per host session. Session cookies set
+// by the response are kept in the fetcher's cookie jar.
+type FormLogin struct {
+	URL          string            `json:"url"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	SecretFields map[string]Secret `json:"secret_fields,omitempty"`
+}
+
+// CredentialProfile describes how to authenticate against a host. Host matches the
+// request host exactly or, with a "*." prefix, any subdomain. Basic and bearer
+// credentials are only sent over https unless AllowHTTP is set.
+type CredentialProfile struct {
+	Host          string            `json:"host"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	SecretHeaders map[string]Secret `json:"secret_headers,omitempty"`
+	BasicAuth     *BasicAuth        `json:"basic_auth,omitempty"`
+	BearerToken   *Secret           `json:"bearer_token,omitempty"`
+	Cookies       map[string]Secret `json:"cookies,omitempty"`
+	FormLogin     *FormLogin        `json:"form_login,omitempty"`
+	AllowHTTP     bool              `json:"allow_http,omitempty"`
+}
+
+func (p *CredentialProfile) matches(host string) bool {
+	host = strings.ToLower(host)
+	hostname, _, _ := strings.Cut(host, ":")
+	pattern := strings.ToLower(p.Host)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(hostname, pattern[1:])
+	}
+	return host == pattern || hostname == pattern
+}
+
+// apply sets the profile's headers, cookies, and authorization on an outgoing request.
+func (p *CredentialProfile) apply(req *http.Request) error {
+	for key, val := range p.Headers {
+		req.Header.Set(key, val)
+	}
+	for key, secret := range p.SecretHeaders {
+		val, err := secret.Resolve()
+		if err != nil {
+			return err
+		}
+		req.Header.Set(key, val)
+	}
+	for name, secret := range p.Cookies {
+		val, err := secret.Resolve()
+		if err != nil {
+			return err
+		}
+		req.AddCookie(&http.Cookie{Name: name, Value: val})
+	}
+	if req.URL.Scheme != "https" && !p.AllowHTTP {
+		return nil
+	}
+	if p.BasicAuth != nil {
+		password, err := p.BasicAuth.Password.Resolve()
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(p.BasicAuth.Username, password)
+	}
+	if p.BearerToken != nil {
+		token, err := p.BearerToken.Resolve()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// DefaultMaxRelogins is the number of times in a row a host's form login is repeated
+// after its session expires.
+const DefaultMaxRelogins = 3
+
+// CredentialStore selects credential profiles by host and tracks form login sessions.
+type CredentialStore struct {
+	// MaxRelogins caps how often in a row the form login of each host is repeated
+	// without a successful fetch in between, so a site that keeps rejecting the session
+	// is not logged into on every request while ordinary expiries are always renewed.
+	MaxRelogins int
+
+	profiles []*CredentialProfile
+	mu       sync.Mutex
+	sessions map[string]*loginSession
+}
+
+type loginSession struct {
+	mu       sync.Mutex
+	loggedIn bool
+	// generation counts successful logins, so concurrent requests that find the same
+	// session expired trigger one login between them.
+	generation int
+	relogins   int
+}
+
+// NewCredentialStore builds a store from the provided profiles.
+func NewCredentialStore(profiles []CredentialProfile) *CredentialStore {
+	store := &CredentialStore{MaxRelogins: DefaultMaxRelogins, sessions: make(map[string]*loginSession)}
+	for i := range profiles {
+		store.profiles = append(store.profiles, &profiles[i])
+	}
+	return store
+}
+
+// LoadCredentialStore reads a JSON array of credential profiles. The file holds only
+// references to secrets, never the secret values themselves.
+func LoadCredentialStore(path string) (*CredentialStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []CredentialProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("parse credential profiles: %w", err)
+	}
+	return NewCredentialStore(profiles), nil
+}
+
+// Profile returns the first profile matching the host, if any.
+func (s *CredentialStore) Profile(host string) (*CredentialProfile, bool) {
+	for _, profile := range s.profiles {
+		if profile.matches(host) {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
+func (s *CredentialStore) session(host string) *loginSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[host]
+	if !ok {
+		sess = &loginSession{}
+		s.sessions[host] = sess
+	}
+	return sess
+}
+
+// ensureLogin submits the profile's login form once per host session and returns the
+// generation of the session.
+func (s *CredentialStore) ensureLogin(client *http.Client, host string, profile *CredentialProfile) (int, error) {
+	sess := s.session(host)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.loggedIn {
+		return sess.generation, nil
+	}
+
+	form := url.Values{}
+	for key, val := range profile.FormLogin.Fields {
+		form.Set(key, val)
+	}
+	for key, secret := range profile.FormLogin.SecretFields {
+		val, err := secret.Resolve()
+		if err != nil {
+			return 0, err
+		}
+		form.Set(key, val)
+	}
+	resp, err := client.PostForm(profile.FormLogin.URL, form)
+	if err != nil {
+		return 0, fmt.Errorf("form login for %s: %w", host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("form login for %s: %s", host, resp.Status)
+	}
+	sess.loggedIn = true
+	sess.generation++
+	return sess.generation, nil
+}
+
+// resetLogin marks the given session generation of the host expired so the next
+// request logs in again. It reports false once the host has used up MaxRelogins since
+// its last successful fetch.
+// A generation that has already been replaced is not reset again.
+func (s *CredentialStore) resetLogin(host string, generation int) bool {
+	sess := s.session(host)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if !sess.loggedIn || sess.generation != generation {
+		return true
+	}
+	if sess.relogins >= s.MaxRelogins {
+		return false
+	}
+	sess.relogins++
+	sess.loggedIn = false
+	return true
+}
+
+// sessionWorked records a successful fetch with the host's session, which restores
+// its allowance of re-logins.
+func (s *CredentialStore) sessionWorked(host string) {
+	sess := s.session(host)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.relogins = 0
+}
+
+// authTransport applies the matching credential profile to every request, including
+// redirects, so credentials never follow a redirect to another host.
+type authTransport struct {
+	base  http.RoundTripper
+	store *CredentialStore
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	profile, ok := t.store.Profile(req.URL.Host)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	clone := req.Clone(req.Context())
+	if err := profile.apply(clone); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(clone)
+}
+
+// stripUserinfo removes credentials embedded in a URL so they never reach documents,
+// logs, or Kafka payloads. Authentication belongs in credential profiles instead.
+func stripUserinfo(raw string) string {
+	if !strings.Contains(raw, "@") {
+		return raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	parsed.User = nil
+	return parsed.String()
+}
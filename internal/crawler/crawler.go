@@ -2,6 +2,8 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,12 +20,21 @@ type DocumentSink interface {
 
 // Crawler walks the web graph starting from a seed frontier.
 type Crawler struct {
-	Fetcher      Fetcher
-	Parser       Parser
-	Logger       telemetry.Logger
-	Workers      int
-	Politeness   time.Duration
-	MaxPages     int
+	Fetcher    Fetcher
+	Parser     Parser
+	Logger     telemetry.Logger
+	Workers    int
+	Politeness time.Duration
+	// MaxPages is the number of URLs the crawler fetches, counted as they leave the
+	// frontier; zero means no limit.
+	MaxPages int
+	// FrontierSize bounds the number of URLs waiting to be fetched. A full frontier
+	// drops its lowest-priority URLs, which may be queued again if rediscovered; zero
+	// means no bound.
+	FrontierSize int
+	// Focus enables topic-focused crawling: the frontier is ordered by link relevance and
+	// links scoring below the focus threshold are pruned. Nil crawls breadth-first.
+	Focus        *TopicFocus
 	visited      sync.Map
 	visitedCount atomic.Int64
 }
@@ -31,12 +42,13 @@ type Crawler struct {
 // New creates a crawler with sane defaults.
 func New(fetcher Fetcher, parser Parser, logger telemetry.Logger) *Crawler {
 	return &Crawler{
-		Fetcher:    fetcher,
-		Parser:     parser,
-		Logger:     logger,
-		Workers:    4,
-		Politeness: 50 * time.Millisecond,
-		MaxPages:   100,
+		Fetcher:      fetcher,
+		Parser:       parser,
+		Logger:       logger,
+		Workers:      4,
+		Politeness:   50 * time.Millisecond,
+		MaxPages:     100,
+		FrontierSize: 10000,
 	}
 }
 
@@ -44,11 +56,12 @@ func New(fetcher Fetcher, parser Parser, logger telemetry.Logger) *Crawler {
 func (c *Crawler) Crawl(ctx context.Context, seeds []string, sink DocumentSink) {
 	defer sink.Close()
 
-	workCh := make(chan string, c.Workers*2)
+	queue := newFrontier(c.FrontierSize)
 	var workerWG sync.WaitGroup
 	var pending sync.WaitGroup
 
-	enqueue := func(url string) {
+	enqueue := func(url string, score float64) {
+		url = stripUserinfo(url)
 		if url == "" {
 			return
 		}
@@ -58,13 +71,14 @@ func (c *Crawler) Crawl(ctx context.Context, seeds []string, sink DocumentSink)
 		if _, seen := c.visited.LoadOrStore(url, struct{}{}); seen {
 			return
 		}
-		if c.MaxPages > 0 && c.visitedCount.Add(1) > int64(c.MaxPages) {
-			return
-		}
 		pending.Add(1)
-		select {
-		case workCh <- url:
-		case <-ctx.Done():
+		kept, evicted := queue.push(url, score)
+		if !kept {
+			c.visited.Delete(url)
+			pending.Done()
+		}
+		if evicted != "" {
+			c.visited.Delete(evicted)
 			pending.Done()
 		}
 	}
@@ -74,23 +88,24 @@ func (c *Crawler) Crawl(ctx context.Context, seeds []string, sink DocumentSink)
 		go func(id int) {
 			defer workerWG.Done()
 			for {
-				select {
-				case <-ctx.Done():
+				item, ok := queue.pop()
+				if !ok {
+					return
+				}
+				if c.MaxPages > 0 && c.visitedCount.Add(1) > int64(c.MaxPages) {
+					// The budget is spent: forget this URL and everything still queued.
+					pending.Add(-1 - queue.close())
 					return
-				case url, ok := <-workCh:
-					if !ok {
+				}
+				c.handleURL(ctx, item.url, sink, enqueue)
+				pending.Done()
+				if c.Politeness > 0 {
+					timer := time.NewTimer(c.Politeness)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
 						return
-					}
-					c.handleURL(ctx, url, sink, enqueue)
-					pending.Done()
-					if c.Politeness > 0 {
-						timer := time.NewTimer(c.Politeness)
-						select {
-						case <-ctx.Done():
-							timer.Stop()
-							return
-						case <-timer.C:
-						}
+					case <-timer.C:
 					}
 				}
 			}
@@ -98,7 +113,7 @@ func (c *Crawler) Crawl(ctx context.Context, seeds []string, sink DocumentSink)
 	}
 
 	for _, seed := range seeds {
-		enqueue(seed)
+		enqueue(seed, math.Inf(1))
 	}
 
 	done := make(chan struct{})
@@ -112,35 +127,67 @@ func (c *Crawler) Crawl(ctx context.Context, seeds []string, sink DocumentSink)
 	case <-done:
 	}
 
-	close(workCh)
+	queue.close()
 	workerWG.Wait()
 }
 
-func (c *Crawler) handleURL(ctx context.Context, target string, sink DocumentSink, enqueue func(string)) {
-	body, err := c.Fetcher.Fetch(target)
+func (c *Crawler) handleURL(ctx context.Context, target string, sink DocumentSink, enqueue func(string, float64)) {
+	resp, err := c.Fetcher.Fetch(target)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Gone() {
+		// The page was removed; tell downstream indexes to forget it.
+		sink.Consume(docs.Tombstone(DocumentID(target)))
+		c.Logger.Info("page_gone", "url", target, "status", statusErr.StatusCode)
+		return
+	}
 	if err != nil {
 		c.Logger.Error("fetch failed", err, "url", target)
 		telemetry.IncCrawlerErrors()
 		return
 	}
 
-	doc, links, err := c.Parser.Parse(target, body)
+	result, err := c.Parser.Parse(target, resp.Body)
 	if err != nil {
 		c.Logger.Error("parse failed", err, "url", target)
 		telemetry.IncCrawlerErrors()
 		return
 	}
 
-	sink.Consume(doc)
-	c.Logger.Info("crawled", "url", target, "out_links", len(links))
-	telemetry.IncCrawlerDocuments()
+	robots := result.Robots.Merge(ParseRobotsDirectives(resp.Header.Values("X-Robots-Tag")...))
+	var pageScore float64
+	if c.Focus != nil {
+		// Pages that may not be indexed still guide the crawl through their links.
+		pageScore = c.Focus.PageScore(result.Title, result.Text)
+	}
+	if result.Document != nil && !robots.NoIndex {
+		sink.Consume(result.Document)
+		telemetry.IncCrawlerDocuments()
+		for _, image := range result.Images {
+			sink.Consume(image)
+		}
+	}
+	c.Logger.Info("crawled", "url", target, "out_links", len(result.Links), "images", len(result.Images), "noindex", robots.NoIndex, "nofollow", robots.NoFollow)
 
-	for _, link := range links {
+	if robots.NoFollow {
+		return
+	}
+	for _, link := range result.Links {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
-		enqueue(link)
+		if link.NoFollow() {
+			continue
+		}
+		var score float64
+		if c.Focus != nil {
+			score = c.Focus.LinkScore(pageScore, link)
+			if !c.Focus.Relevant(score) {
+				telemetry.IncCrawlerPruned()
+				continue
+			}
+		}
+		enqueue(link.URL, score)
 	}
 }
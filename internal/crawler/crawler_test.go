@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/semantic"
+)
+
+type stubFetcher struct {
+	pages map[string]*Response
+}
+
+func (f *stubFetcher) Fetch(target string) (*Response, error) {
+	resp, ok := f.pages[target]
+	if !ok {
+		return nil, errors.New("404 Not Found")
+	}
+	return resp, nil
+}
+
+type recordingSink struct {
+	mu   sync.Mutex
+	docs []*docs.Document
+}
+
+func (s *recordingSink) Consume(doc *docs.Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs = append(s.docs, doc)
+}
+
+func (s *recordingSink) Close() {}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+
+func TestCrawlerHonorsRobotsDirectives(t *testing.T) {
+	page := func(body string, header http.Header) *Response {
+		return &Response{StatusCode: http.StatusOK, Header: header, Body: body}
+	}
+	fetcher := &stubFetcher{pages: map[string]*Response{
+		"http://site/":       page(`<html><head><meta name="robots" content="noindex"></head><body><a href="/a">A</a><a href="/b" rel="nofollow">B</a></body></html>`, nil),
+		"http://site/a":      page(`<html><body>Alpha <a href="/hidden">Hidden</a></body></html>`, http.Header{"X-Robots-Tag": {"nofollow"}}),
+		"http://site/b":      page(`<html><body>Beta</body></html>`, nil),
+		"http://site/hidden": page(`<html><body>Hidden</body></html>`, nil),
+	}}
+
+	c := New(fetcher, &HTMLParser{}, nopLogger{})
+	c.Politeness = 0
+	sink := &recordingSink{}
+	c.Crawl(context.Background(), []string{"http://site/"}, sink)
+
+	if len(sink.docs) != 1 || sink.docs[0].URL != "http://site/a" {
+		t.Fatalf("expected only http://site/a to be indexed, got %+v", sink.docs)
+	}
+}
+
+func TestFocusedCrawlOrdersAndPrunesLinks(t *testing.T) {
+	page := func(body string) *Response {
+		return &Response{StatusCode: http.StatusOK, Body: body}
+	}
+	fetcher := &stubFetcher{pages: map[string]*Response{
+		"http://site/": page(`<html><body>Welcome
+<a href="/cooking">pasta recipes</a>
+<a href="/partial">raft notes</a>
+<a href="/consensus">distributed consensus raft</a>
+</body></html>`),
+		"http://site/cooking":   page(`<html><body>Pasta</body></html>`),
+		"http://site/partial":   page(`<html><body>Raft</body></html>`),
+		"http://site/consensus": page(`<html><body>Consensus</body></html>`),
+	}}
+
+	c := New(fetcher, &HTMLParser{}, nopLogger{})
+	c.Workers = 1
+	c.Politeness = 0
+	c.Focus = NewTopicFocus(semantic.NewHashingEmbedder(512), "distributed consensus raft", 0.5)
+	sink := &recordingSink{}
+	c.Crawl(context.Background(), []string{"http://site/"}, sink)
+
+	var got []string
+	for _, doc := range sink.docs {
+		got = append(got, doc.URL)
+	}
+	want := []string{"http://site/", "http://site/consensus", "http://site/partial"}
+	if len(got) != len(want) {
+		t.Fatalf("expected crawl order %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected crawl order %v, got %v", want, got)
+		}
+	}
+}
+
+func TestFocusedCrawlScoresNoindexPages(t *testing.T) {
+	page := func(body string) *Response {
+		return &Response{StatusCode: http.StatusOK, Body: body}
+	}
+	fetcher := &stubFetcher{pages: map[string]*Response{
+		"http://site/": page(`<html><head><meta name="robots" content="noindex"></head>
+<body>Distributed consensus with raft <a href="/next"></a></body></html>`),
+		"http://site/next": page(`<html><body>Raft log replication</body></html>`),
+	}}
+
+	c := New(fetcher, &HTMLParser{}, nopLogger{})
+	c.Politeness = 0
+	c.Focus = NewTopicFocus(semantic.NewHashingEmbedder(512), "distributed consensus raft", 0.5)
+	sink := &recordingSink{}
+	c.Crawl(context.Background(), []string{"http://site/"}, sink)
+
+	if len(sink.docs) != 1 || sink.docs[0].URL != "http://site/next" {
+		t.Fatalf("expected the link of the noindex page to inherit its score, got %+v", sink.docs)
+	}
+}
+
+func TestCrawlBudgetIsChargedWhenURLsLeaveTheFrontier(t *testing.T) {
+	page := func(body string) *Response {
+		return &Response{StatusCode: http.StatusOK, Body: body}
+	}
+	fetcher := &stubFetcher{pages: map[string]*Response{
+		"http://site/": page(`<html><body>Welcome
+<a href="/cooking">pasta recipes</a>
+<a href="/consensus">distributed consensus raft</a>
+</body></html>`),
+		"http://site/cooking":   page(`<html><body>Pasta</body></html>`),
+		"http://site/consensus": page(`<html><body>Consensus</body></html>`),
+	}}
+
+	c := New(fetcher, &HTMLParser{}, nopLogger{})
+	c.Workers = 1
+	c.Politeness = 0
+	c.MaxPages = 2
+	c.Focus = NewTopicFocus(semantic.NewHashingEmbedder(512), "distributed consensus raft", -1)
+	sink := &recordingSink{}
+	c.Crawl(context.Background(), []string{"http://site/"}, sink)
+
+	if len(sink.docs) != 2 || sink.docs[1].URL != "http://site/consensus" {
+		t.Fatalf("expected the budget to go to the best link, got %+v", sink.docs)
+	}
+}
+
+func TestFrontierDropsLowestPriorityWhenFull(t *testing.T) {
+	f := newFrontier(2)
+	f.push("a", 1)
+	f.push("b", 3)
+	if kept, evicted := f.push("c", 2); !kept || evicted != "a" {
+		t.Fatalf("expected c to evict a, got %v, %q", kept, evicted)
+	}
+	if kept, _ := f.push("d", 0); kept {
+		t.Fatalf("expected d to be dropped by a full frontier")
+	}
+	for _, want := range []string{"b", "c"} {
+		if item, ok := f.pop(); !ok || item.url != want {
+			t.Fatalf("expected %s, got %+v", want, item)
+		}
+	}
+	f.push("e", 0)
+	if dropped := f.close(); dropped != 1 {
+		t.Fatalf("expected close to discard 1 queued URL, got %d", dropped)
+	}
+}
+
+type goneFetcher struct{}
+
+func (goneFetcher) Fetch(target string) (*Response, error) {
+	return nil, &StatusError{URL: target, StatusCode: http.StatusGone, Status: "410 Gone"}
+}
+
+func TestCrawlerEmitsTombstoneForGonePages(t *testing.T) {
+	c := New(goneFetcher{}, &HTMLParser{}, nopLogger{})
+	c.Politeness = 0
+	sink := &recordingSink{}
+	c.Crawl(context.Background(), []string{"http://site/removed"}, sink)
+
+	if len(sink.docs) != 1 || !sink.docs[0].Deleted || sink.docs[0].ID != DocumentID("http://site/removed") {
+		t.Fatalf("expected a tombstone for the removed page, got %+v", sink.docs)
+	}
+}
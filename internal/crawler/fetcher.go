@@ -1,58 +1,176 @@
 package crawler
 
 import (
-	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/publicsuffix"
 )
 
-// Fetcher retrieves the raw body for a given URL.
+// Response is the raw result of fetching a URL.
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// StatusError reports an HTTP error status returned for a fetched URL.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetch %s: %s", e.URL, e.Status)
+}
+
+// Gone reports whether the status means the page no longer exists (404 or 410).
+func (e *StatusError) Gone() bool {
+	return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+}
+
+// Fetcher retrieves the raw body and response metadata for a given URL.
 type Fetcher interface {
-	Fetch(target string) (string, error)
+	Fetch(target string) (*Response, error)
 }
 
 // HTTPFetcher implements Fetcher using the net/http client.
 type HTTPFetcher struct {
 	Client  *http.Client
 	Backoff time.Duration
+	// Credentials authenticates requests to hosts with a matching profile. When set, the
+	// fetcher keeps a cookie jar so form login sessions persist across requests.
+	Credentials *CredentialStore
+
+	clientOnce sync.Once
+	client     *http.Client
 }
 
-// Fetch returns the body for both http(s) and file scheme URLs.
-func (f *HTTPFetcher) Fetch(target string) (string, error) {
+// Fetch returns the response for both http(s) and file scheme URLs.
+func (f *HTTPFetcher) Fetch(target string) (*Response, error) {
 	if strings.HasPrefix(target, "file://") {
 		parsed, err := url.Parse(target)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		path := filepath.Clean(parsed.Path)
 		data, err := os.ReadFile(path)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return string(data), nil
+		return &Response{URL: target, StatusCode: http.StatusOK, Header: http.Header{}, Body: string(data)}, nil
 	}
 
-	client := f.Client
-	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+	client := f.httpClient()
+	profile, host := f.loginProfile(target)
+	var generation int
+	if profile != nil {
+		var err error
+		if generation, err = f.Credentials.ensureLogin(client, host, profile); err != nil {
+			return nil, err
+		}
 	}
 
 	resp, err := client.Get(target)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if profile != nil && sessionExpired(resp, target, profile) && f.Credentials.resetLogin(host, generation) {
+		// The session expired; log in again and retry once. A 403 means the session is
+		// valid but lacks access, so it is not retried.
+		resp.Body.Close()
+		if _, err := f.Credentials.ensureLogin(client, host, profile); err != nil {
+			return nil, err
+		}
+		if resp, err = client.Get(target); err != nil {
+			return nil, err
+		}
 	}
 	defer resp.Body.Close()
+	if profile != nil && redirectedToLogin(resp, target, profile) {
+		return nil, fmt.Errorf("fetch %s: redirected to the login page of %s", target, host)
+	}
 	if resp.StatusCode >= 400 {
-		return "", errors.New(resp.Status)
+		return nil, &StatusError{URL: target, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if profile != nil {
+		f.Credentials.sessionWorked(host)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return &Response{URL: target, StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}, nil
+}
+
+// sessionExpired reports whether the response asks for a new login: a 401, or a
+// redirect that ended on the profile's login page.
+func sessionExpired(resp *http.Response, target string, profile *CredentialProfile) bool {
+	return resp.StatusCode == http.StatusUnauthorized || redirectedToLogin(resp, target, profile)
+}
+
+// redirectedToLogin reports whether fetching target was redirected to the login form.
+func redirectedToLogin(resp *http.Response, target string, profile *CredentialProfile) bool {
+	if resp.Request == nil || resp.Request.URL.String() == target {
+		return false
+	}
+	login, err := url.Parse(profile.FormLogin.URL)
+	if err != nil {
+		return false
+	}
+	final := resp.Request.URL
+	return strings.EqualFold(final.Host, login.Host) && final.Path == login.Path
+}
+
+func (f *HTTPFetcher) httpClient() *http.Client {
+	f.clientOnce.Do(func() {
+		client := f.Client
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+		if f.Credentials == nil {
+			f.client = client
+			return
+		}
+		wrapped := *client
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		wrapped.Transport = &authTransport{base: base, store: f.Credentials}
+		if wrapped.Jar == nil {
+			jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+			if err == nil {
+				wrapped.Jar = jar
+			}
+		}
+		f.client = &wrapped
+	})
+	return f.client
+}
+
+// loginProfile returns the credential profile requiring a form login for the target host.
+func (f *HTTPFetcher) loginProfile(target string) (*CredentialProfile, string) {
+	if f.Credentials == nil {
+		return nil, ""
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, ""
+	}
+	profile, ok := f.Credentials.Profile(parsed.Host)
+	if !ok || profile.FormLogin == nil {
+		return nil, ""
 	}
-	return string(body), nil
+	return profile, parsed.Host
 }
@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/eshwanth/distributed-search-engine/internal/semantic"
+)
+
+// TopicFocus scores discovered links by how closely they relate to a target topic so
+// that a focused crawl spends its budget on on-topic pages.
+type TopicFocus struct {
+	Embedder semantic.Embedder
+	// Threshold prunes links whose score falls below it.
+	Threshold float64
+	// AnchorWeight balances anchor text similarity against source page similarity.
+	AnchorWeight float64
+	topic        semantic.Vector
+}
+
+// NewTopicFocus embeds the topic description once and returns a scorer with default weights.
+func NewTopicFocus(embedder semantic.Embedder, topic string, threshold float64) *TopicFocus {
+	return &TopicFocus{
+		Embedder:     embedder,
+		Threshold:    threshold,
+		AnchorWeight: 0.4,
+		topic:        embedder.EmbedText(topic),
+	}
+}
+
+// PageScore returns the similarity between the topic and the page title and content.
+func (f *TopicFocus) PageScore(title, content string) float64 {
+	return semantic.CosineSimilarity(f.topic, f.Embedder.EmbedText(title+" "+content))
+}
+
+// LinkScore combines the source page score with the similarity of the link's anchor text.
+// Links without anchor text inherit the page score.
+func (f *TopicFocus) LinkScore(pageScore float64, link Link) float64 {
+	text := strings.TrimSpace(link.Text)
+	if text == "" {
+		return pageScore
+	}
+	anchor := semantic.CosineSimilarity(f.topic, f.Embedder.EmbedText(text))
+	return (1-f.AnchorWeight)*pageScore + f.AnchorWeight*anchor
+}
+
+// Relevant reports whether a link score clears the pruning threshold.
+func (f *TopicFocus) Relevant(score float64) bool {
+	return score >= f.Threshold
+}
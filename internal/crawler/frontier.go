@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// frontier is a blocking priority queue of URLs ordered by score, then discovery order.
+// It holds at most size URLs; when full, the lowest-priority URL gives way.
+type frontier struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  frontierHeap
+	size   int
+	seq    uint64
+	closed bool
+}
+
+type frontierItem struct {
+	url   string
+	score float64
+	seq   uint64
+}
+
+// newFrontier returns an empty frontier of the given size; zero or less is unbounded.
+func newFrontier(size int) *frontier {
+	f := &frontier{size: size}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// push adds a URL and reports whether it was kept. It is dropped when the frontier has
+// been closed, or when the frontier is full and every queued URL outranks it; otherwise
+// a full frontier evicts its lowest-priority URL, which is returned.
+func (f *frontier) push(url string, score float64) (kept bool, evicted string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false, ""
+	}
+	f.seq++
+	item := frontierItem{url: url, score: score, seq: f.seq}
+	if f.size > 0 && len(f.items) >= f.size {
+		last := f.items.lowest()
+		if !f.items.less(item, f.items[last]) {
+			return false, ""
+		}
+		evicted = f.items[last].url
+		f.items[last] = item
+		heap.Fix(&f.items, last)
+		return true, evicted
+	}
+	heap.Push(&f.items, item)
+	f.cond.Signal()
+	return true, ""
+}
+
+// pop blocks until a URL is available or the frontier is closed.
+func (f *frontier) pop() (frontierItem, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.items) == 0 && !f.closed {
+		f.cond.Wait()
+	}
+	if f.closed {
+		return frontierItem{}, false
+	}
+	return heap.Pop(&f.items).(frontierItem), true
+}
+
+// close wakes every waiting pop and returns how many queued URLs were discarded.
+func (f *frontier) close() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0
+	}
+	f.closed = true
+	dropped := len(f.items)
+	f.items = nil
+	f.cond.Broadcast()
+	return dropped
+}
+
+type frontierHeap []frontierItem
+
+func (h frontierHeap) Len() int { return len(h) }
+
+func (h frontierHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+
+// less reports whether a is crawled before b.
+func (frontierHeap) less(a, b frontierItem) bool {
+	if a.score == b.score {
+		return a.seq < b.seq
+	}
+	return a.score > b.score
+}
+
+// lowest returns the index of the item that would be crawled last. It is always a leaf.
+func (h frontierHeap) lowest() int {
+	last := len(h) / 2
+	for i := last + 1; i < len(h); i++ {
+		if h.less(h[last], h[i]) {
+			last = i
+		}
+	}
+	return last
+}
+
+func (h frontierHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *frontierHeap) Push(x any) { *h = append(*h, x.(frontierItem)) }
+
+func (h *frontierHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -10,36 +10,81 @@ import (
 	"golang.org/x/net/html"
 )
 
+// Link is an outbound reference discovered while parsing a page.
+type Link struct {
+	URL  string
+	Tag  string
+	Rel  []string
+	Text string
+}
+
+// HasRel reports whether the link carries the provided rel value.
+func (l Link) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// NoFollow reports whether the link asks crawlers not to follow it.
+func (l Link) NoFollow() bool {
+	return l.HasRel("nofollow")
+}
+
+// ParseResult bundles the document, images, links, and robots directives extracted from a page.
+// Document and Images are empty when the page opts out of indexing via <meta name="robots">,
+// while Title and Text are always set so a focused crawl can still score the page.
+type ParseResult struct {
+	Title    string
+	Text     string
+	Document *docs.Document
+	Images   []*docs.Document
+	Links    []Link
+	Robots   RobotsDirectives
+}
+
 // Parser extracts structured data and discovered links from raw HTML.
 type Parser interface {
-	Parse(baseURL string, htmlBody string) (*docs.Document, []string, error)
+	Parse(baseURL string, htmlBody string) (*ParseResult, error)
 }
 
 // HTMLParser parses HTML documents extracting their title, textual content, and hyperlinks.
 type HTMLParser struct{}
 
-// Parse returns a Document alongside discovered links.
-func (p *HTMLParser) Parse(baseURL string, htmlBody string) (*docs.Document, []string, error) {
+// Parse returns a Document alongside discovered links and the page's robots directives.
+func (p *HTMLParser) Parse(baseURL string, htmlBody string) (*ParseResult, error) {
 	node, err := html.Parse(strings.NewReader(htmlBody))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	title := extractTitle(node)
-	text := extractText(node)
-	links := extractLinks(node, baseURL)
-
-	sum := sha1.Sum([]byte(baseURL))
-	id := fmt.Sprintf("%x", sum[:])
+	result := &ParseResult{
+		Title:  extractTitle(node),
+		Text:   extractText(node),
+		Links:  extractLinks(node, baseURL),
+		Robots: extractRobots(node),
+	}
+	if result.Robots.NoIndex {
+		return result, nil
+	}
 
-	doc := &docs.Document{
-		ID:      id,
+	result.Document = &docs.Document{
+		ID:      DocumentID(baseURL),
 		URL:     baseURL,
-		Title:   title,
-		Content: text,
+		Title:   result.Title,
+		Content: result.Text,
+		Type:    docs.TypePage,
 	}
+	result.Images = extractImages(node, result.Document)
+	return result, nil
+}
 
-	return doc, links, nil
+// DocumentID returns the document ID the crawler assigns to a URL.
+func DocumentID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return fmt.Sprintf("%x", sum[:])
 }
 
 func extractTitle(node *html.Node) string {
@@ -73,17 +118,102 @@ func extractText(node *html.Node) string {
 	return buf.String()
 }
 
-func extractLinks(node *html.Node, base string) []string {
-	var links []string
+func extractRobots(node *html.Node) RobotsDirectives {
+	var values []string
 	var traverse func(*html.Node)
 	traverse = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, attr := range n.Attr {
-				if attr.Key == "href" {
-					links = append(links, resolveLink(base, attr.Val))
-					break
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(attrValue(n, "name"), "robots") {
+			values = append(values, attrValue(n, "content"))
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(node)
+	return ParseRobotsDirectives(values...)
+}
+
+// maxCaptionLength bounds the surrounding text used as a caption for images outside <figure>.
+const maxCaptionLength = 200
+
+func extractImages(node *html.Node, page *docs.Document) []*docs.Document {
+	var images []*docs.Document
+	seen := make(map[string]struct{})
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "img" {
+			src := strings.TrimSpace(attrValue(n, "src"))
+			if src != "" && isCrawlableScheme(src) {
+				resolved := resolveLink(page.URL, src)
+				if _, dup := seen[resolved]; !dup {
+					seen[resolved] = struct{}{}
+					alt := strings.TrimSpace(attrValue(n, "alt"))
+					title := strings.TrimSpace(attrValue(n, "title"))
+					caption := imageCaption(n)
+					images = append(images, &docs.Document{
+						ID:       DocumentID(page.URL + "#image=" + resolved),
+						URL:      resolved,
+						Title:    title,
+						Content:  strings.TrimSpace(strings.Join([]string{alt, title, caption}, " ")),
+						Type:     docs.TypeImage,
+						ParentID: page.ID,
+						Image: &docs.ImageInfo{
+							Alt:       alt,
+							Caption:   caption,
+							PageURL:   page.URL,
+							PageTitle: page.Title,
+						},
+					})
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(node)
+	return images
+}
+
+// imageCaption prefers the <figcaption> of an enclosing <figure> and falls back to the
+// text of the image's parent element.
+func imageCaption(img *html.Node) string {
+	for p := img.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "figure" {
+			for child := p.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.ElementNode && child.Data == "figcaption" {
+					return strings.TrimSpace(extractText(child))
 				}
 			}
+			break
+		}
+	}
+	parent := img.Parent
+	if parent == nil || parent.Type != html.ElementNode || parent.Data == "body" || parent.Data == "html" {
+		return ""
+	}
+	caption := strings.TrimSpace(extractText(parent))
+	if runes := []rune(caption); len(runes) > maxCaptionLength {
+		caption = string(runes[:maxCaptionLength])
+	}
+	return caption
+}
+
+// followableLinkRels lists the <link rel> values that point at crawlable pages.
+var followableLinkRels = map[string]bool{
+	"alternate": true,
+	"next":      true,
+	"prev":      true,
+}
+
+func extractLinks(node *html.Node, base string) []Link {
+	var links []Link
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if link, ok := linkFromElement(n, base); ok {
+				links = append(links, link)
+			}
 		}
 		for child := n.FirstChild; child != nil; child = child.NextSibling {
 			traverse(child)
@@ -93,6 +223,71 @@ func extractLinks(node *html.Node, base string) []string {
 	return links
 }
 
+func linkFromElement(n *html.Node, base string) (Link, bool) {
+	var target string
+	switch n.Data {
+	case "a", "area":
+		target = attrValue(n, "href")
+	case "link":
+		target = attrValue(n, "href")
+		followable := false
+		for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+			if followableLinkRels[rel] {
+				followable = true
+				break
+			}
+		}
+		if !followable {
+			return Link{}, false
+		}
+	case "iframe":
+		target = attrValue(n, "src")
+	default:
+		return Link{}, false
+	}
+
+	target = strings.TrimSpace(target)
+	if target == "" || strings.HasPrefix(target, "#") || !isCrawlableScheme(target) {
+		return Link{}, false
+	}
+
+	link := Link{
+		URL: resolveLink(base, target),
+		Tag: n.Data,
+		Rel: strings.Fields(strings.ToLower(attrValue(n, "rel"))),
+	}
+	switch n.Data {
+	case "a":
+		link.Text = strings.TrimSpace(extractText(n))
+	case "area":
+		link.Text = attrValue(n, "alt")
+	case "link", "iframe":
+		link.Text = attrValue(n, "title")
+	}
+	return link, true
+}
+
+func isCrawlableScheme(href string) bool {
+	idx := strings.Index(href, ":")
+	if idx < 0 {
+		return true
+	}
+	switch strings.ToLower(href[:idx]) {
+	case "javascript", "mailto", "tel", "data":
+		return false
+	}
+	return true
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
 func resolveLink(base string, href string) string {
 	if strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "file://") {
 		return href
@@ -5,17 +5,113 @@ import "testing"
 func TestHTMLParserExtractsTitleAndLinks(t *testing.T) {
 	const html = `<!DOCTYPE html><html><head><title>Sample</title></head><body><a href="next.html">Next</a></body></html>`
 	parser := &HTMLParser{}
-	doc, links, err := parser.Parse("file:///tmp/sample.html", html)
+	result, err := parser.Parse("file:///tmp/sample.html", html)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if doc.Title != "Sample" {
-		t.Fatalf("expected title Sample, got %s", doc.Title)
+	if result.Document.Title != "Sample" {
+		t.Fatalf("expected title Sample, got %s", result.Document.Title)
 	}
-	if len(links) != 1 {
-		t.Fatalf("expected 1 link, got %d", len(links))
+	if len(result.Links) != 1 {
+		t.Fatalf("expected 1 link, got %d", len(result.Links))
 	}
-	if links[0] != "file:///tmp/next.html" {
-		t.Fatalf("expected resolved link, got %s", links[0])
+	if result.Links[0].URL != "file:///tmp/next.html" {
+		t.Fatalf("expected resolved link, got %s", result.Links[0].URL)
+	}
+	if result.Links[0].Text != "Next" {
+		t.Fatalf("expected anchor text Next, got %s", result.Links[0].Text)
+	}
+}
+
+func TestHTMLParserExtractsTypedLinksAndRobots(t *testing.T) {
+	const html = `<html><head>
+<meta name="robots" content="noindex">
+<link rel="next" href="page2.html">
+<link rel="stylesheet" href="style.css">
+</head><body>
+<a href="ad.html" rel="sponsored nofollow">Ad</a>
+<a href="mailto:ops@example.com">Mail</a>
+<map><area href="region.html" alt="Region"></map>
+<iframe src="embed.html"></iframe>
+</body></html>`
+	parser := &HTMLParser{}
+	result, err := parser.Parse("file:///tmp/index.html", html)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Document != nil {
+		t.Fatalf("expected noindex page to produce no document")
+	}
+	if !result.Robots.NoIndex || result.Robots.NoFollow {
+		t.Fatalf("unexpected robots directives: %+v", result.Robots)
+	}
+
+	expected := map[string]string{
+		"file:///tmp/page2.html":  "link",
+		"file:///tmp/ad.html":     "a",
+		"file:///tmp/region.html": "area",
+		"file:///tmp/embed.html":  "iframe",
+	}
+	if len(result.Links) != len(expected) {
+		t.Fatalf("expected %d links, got %+v", len(expected), result.Links)
+	}
+	for _, link := range result.Links {
+		if tag, ok := expected[link.URL]; !ok || tag != link.Tag {
+			t.Fatalf("unexpected link %+v", link)
+		}
+		if link.URL == "file:///tmp/ad.html" && !link.NoFollow() {
+			t.Fatalf("expected ad link to be nofollow")
+		}
+	}
+}
+
+func TestParseRobotsDirectives(t *testing.T) {
+	cases := []struct {
+		values []string
+		want   RobotsDirectives
+	}{
+		{[]string{"noindex, nofollow"}, RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{[]string{"NONE"}, RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{[]string{"otherbot: noindex", "nofollow"}, RobotsDirectives{NoFollow: true}},
+		{[]string{"otherbot: noindex, nofollow"}, RobotsDirectives{}},
+		{[]string{"otherbot: noindex, robots: nofollow"}, RobotsDirectives{NoFollow: true}},
+		{[]string{"max-snippet: 20, noindex"}, RobotsDirectives{NoIndex: true}},
+		{[]string{"otherbot: unavailable_after: 2025-01-01, nofollow"}, RobotsDirectives{}},
+		{[]string{"all"}, RobotsDirectives{}},
+	}
+	for _, tc := range cases {
+		if got := ParseRobotsDirectives(tc.values...); got != tc.want {
+			t.Fatalf("ParseRobotsDirectives(%v) = %+v, want %+v", tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestHTMLParserExtractsImages(t *testing.T) {
+	const html = `<html><head><title>Gallery</title></head><body>
+<figure><img src="img/raft.png" alt="Raft leader election" title="Raft"><figcaption>Leader election timeline</figcaption></figure>
+<p>Cluster topology <img src="/topology.svg"></p>
+<img src="data:image/png;base64,AAAA">
+</body></html>`
+	parser := &HTMLParser{}
+	result, err := parser.Parse("http://example.com/gallery", html)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Images) != 2 {
+		t.Fatalf("expected 2 images, got %d", len(result.Images))
+	}
+
+	figure := result.Images[0]
+	if !figure.IsImage() || figure.ParentID != result.Document.ID {
+		t.Fatalf("expected image linked to parent page, got %+v", figure)
+	}
+	if figure.URL != "http://example.com/gallery/img/raft.png" {
+		t.Fatalf("expected resolved image URL, got %s", figure.URL)
+	}
+	if figure.Image.Alt != "Raft leader election" || figure.Image.Caption != "Leader election timeline" {
+		t.Fatalf("unexpected image attributes: %+v", figure.Image)
+	}
+	if result.Images[1].Image.Caption != "Cluster topology" {
+		t.Fatalf("expected surrounding text caption, got %q", result.Images[1].Image.Caption)
 	}
 }
@@ -0,0 +1,60 @@
+package crawler
+
+import "strings"
+
+// RobotsDirectives captures the indexing and link-following rules a page declares
+// through <meta name="robots"> or X-Robots-Tag headers.
+type RobotsDirectives struct {
+	NoIndex  bool
+	NoFollow bool
+}
+
+// Merge combines two directive sets, keeping the most restrictive value of each rule.
+func (r RobotsDirectives) Merge(other RobotsDirectives) RobotsDirectives {
+	return RobotsDirectives{
+		NoIndex:  r.NoIndex || other.NoIndex,
+		NoFollow: r.NoFollow || other.NoFollow,
+	}
+}
+
+// ParseRobotsDirectives parses comma separated robots values such as "noindex, nofollow".
+// An agent prefix (for example "otherbot: noindex, nofollow") scopes every directive after
+// it in the same value, up to the next agent prefix. Directives scoped to another user
+// agent are ignored.
+func ParseRobotsDirectives(values ...string) RobotsDirectives {
+	var directives RobotsDirectives
+	for _, value := range values {
+		applies := true
+		for _, part := range strings.Split(value, ",") {
+			token := strings.ToLower(strings.TrimSpace(part))
+			if name, rest, ok := strings.Cut(token, ":"); ok {
+				if agent := strings.TrimSpace(name); !valuedRobotsDirectives[agent] {
+					applies = agent == "robots" || agent == "*"
+					token = strings.TrimSpace(rest)
+				}
+			}
+			if !applies {
+				continue
+			}
+			switch token {
+			case "noindex":
+				directives.NoIndex = true
+			case "nofollow":
+				directives.NoFollow = true
+			case "none":
+				directives.NoIndex = true
+				directives.NoFollow = true
+			}
+		}
+	}
+	return directives
+}
+
+// valuedRobotsDirectives are directives written as "name: value", which must not be
+// mistaken for a user agent prefix.
+var valuedRobotsDirectives = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
@@ -2,6 +2,12 @@ package docs
 
 import "time"
 
+// Document types distinguish crawled pages from documents derived from them.
+const (
+	TypePage  = "page"
+	TypeImage = "image"
+)
+
 // Document represents a crawled web page that can be indexed.
 type Document struct {
 	ID        string
@@ -10,4 +16,31 @@ type Document struct {
 	Content   string
 	Tokens    []string
 	FetchedAt time.Time
+	// Type is TypePage or TypeImage; an empty type is treated as a page.
+	Type string
+	// ParentID links derived documents, such as images, to the page they were found on.
+	ParentID string
+	Image    *ImageInfo
+	// Metadata holds arbitrary attributes carried over from imported records.
+	Metadata map[string]string
+	// Deleted marks a tombstone: the document with this ID should be removed everywhere.
+	Deleted bool
+}
+
+// Tombstone returns a deletion marker for the document with the given ID.
+func Tombstone(id string) *Document {
+	return &Document{ID: id, Deleted: true}
+}
+
+// ImageInfo carries the attributes of an image document.
+type ImageInfo struct {
+	Alt       string
+	Caption   string
+	PageURL   string
+	PageTitle string
+}
+
+// IsImage reports whether the document describes an image rather than a page.
+func (d *Document) IsImage() bool {
+	return d.Type == TypeImage
 }
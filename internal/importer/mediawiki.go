@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"context"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// WikiPage is a single page read from a MediaWiki XML dump.
+type WikiPage struct {
+	ID        int64
+	Title     string
+	Namespace int
+	Redirect  string
+	Timestamp time.Time
+	Text      string
+}
+
+type dumpPage struct {
+	ID        int64  `xml:"id"`
+	Title     string `xml:"title"`
+	Namespace int    `xml:"ns"`
+	Redirect  *struct {
+		Title string `xml:"title,attr"`
+	} `xml:"redirect"`
+	Revisions []struct {
+		Timestamp string `xml:"timestamp"`
+		Text      string `xml:"text"`
+	} `xml:"revision"`
+}
+
+// DumpReader streams pages from a MediaWiki XML export without loading it into memory.
+type DumpReader struct {
+	decoder *xml.Decoder
+}
+
+// NewDumpReader wraps r, transparently decompressing bzip2 input.
+func NewDumpReader(r io.Reader) (*DumpReader, error) {
+	buffered := bufio.NewReaderSize(r, 1<<16)
+	magic, err := buffered.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var src io.Reader = buffered
+	if string(magic) == "BZh" {
+		src = bzip2.NewReader(buffered)
+	}
+	return &DumpReader{decoder: xml.NewDecoder(src)}, nil
+}
+
+// Next returns the next page in the dump, or io.EOF once the dump is exhausted.
+func (d *DumpReader) Next() (*WikiPage, error) {
+	for {
+		tok, err := d.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+		var raw dumpPage
+		if err := d.decoder.DecodeElement(&raw, &start); err != nil {
+			return nil, fmt.Errorf("decode page: %w", err)
+		}
+		page := &WikiPage{ID: raw.ID, Title: raw.Title, Namespace: raw.Namespace}
+		if raw.Redirect != nil {
+			page.Redirect = raw.Redirect.Title
+		}
+		if n := len(raw.Revisions); n > 0 {
+			latest := raw.Revisions[n-1]
+			page.Text = latest.Text
+			if ts, err := time.Parse(time.RFC3339, latest.Timestamp); err == nil {
+				page.Timestamp = ts
+			}
+		}
+		return page, nil
+	}
+}
+
+// MediaWikiOptions controls which pages are imported and how they are addressed.
+type MediaWikiOptions struct {
+	// BaseURL is prefixed to the page title to build document URLs.
+	BaseURL string
+	// Namespaces restricts the import to the given namespaces; empty imports only articles (0).
+	Namespaces []int
+	// IncludeRedirects imports redirect pages, which are skipped by default.
+	IncludeRedirects bool
+	// MaxPages stops the import after publishing this many documents; zero means no limit.
+	MaxPages int
+	// ProgressEvery logs progress after this many published documents; zero disables it.
+	ProgressEvery int
+	Logger        telemetry.Logger
+}
+
+// ImportStats summarizes an import run.
+type ImportStats struct {
+	Read      int
+	Published int
+	Skipped   int
+	Rejected  int
+}
+
+// ImportMediaWiki streams a MediaWiki dump into sink. The caller owns the sink and is
+// responsible for closing it.
+func ImportMediaWiki(ctx context.Context, r io.Reader, sink crawler.DocumentSink, opts MediaWikiOptions) (ImportStats, error) {
+	var stats ImportStats
+	reader, err := NewDumpReader(r)
+	if err != nil {
+		return stats, err
+	}
+	namespaces := map[int]bool{0: true}
+	if len(opts.Namespaces) > 0 {
+		namespaces = make(map[int]bool, len(opts.Namespaces))
+		for _, ns := range opts.Namespaces {
+			namespaces[ns] = true
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if opts.MaxPages > 0 && stats.Published >= opts.MaxPages {
+			return stats, nil
+		}
+		page, err := reader.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Read++
+
+		if !namespaces[page.Namespace] || (page.Redirect != "" && !opts.IncludeRedirects) {
+			stats.Skipped++
+			continue
+		}
+		doc := WikiDocument(page, opts.BaseURL)
+		if doc.Content == "" {
+			stats.Skipped++
+			continue
+		}
+		sink.Consume(doc)
+		stats.Published++
+		if opts.Logger != nil && opts.ProgressEvery > 0 && stats.Published%opts.ProgressEvery == 0 {
+			opts.Logger.Info("wiki_import_progress", "read", stats.Read, "published", stats.Published, "skipped", stats.Skipped)
+		}
+	}
+}
+
+// WikiDocument converts a dump page into a document, stripping wikitext to plain text.
+func WikiDocument(page *WikiPage, baseURL string) *docs.Document {
+	pageURL := baseURL + url.PathEscape(strings.ReplaceAll(page.Title, " ", "_"))
+	sum := sha1.Sum([]byte(pageURL))
+	return &docs.Document{
+		ID:        fmt.Sprintf("%x", sum[:]),
+		URL:       pageURL,
+		Title:     page.Title,
+		Content:   StripWikitext(page.Text),
+		FetchedAt: page.Timestamp,
+		Type:      docs.TypePage,
+	}
+}
@@ -0,0 +1,66 @@
+package importer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/importer"
+)
+
+type collectingSink struct {
+	docs []*docs.Document
+}
+
+func (s *collectingSink) Consume(doc *docs.Document) { s.docs = append(s.docs, doc) }
+func (s *collectingSink) Close()                     {}
+
+func TestImportMediaWikiDump(t *testing.T) {
+	for _, name := range []string{"enwiki-sample.xml", "enwiki-sample.xml.bz2"} {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("open fixture: %v", err)
+			}
+			defer file.Close()
+
+			sink := &collectingSink{}
+			stats, err := importer.ImportMediaWiki(context.Background(), file, sink, importer.MediaWikiOptions{BaseURL: "https://en.wikipedia.org/wiki/"})
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			if stats.Read != 3 || stats.Published != 1 || stats.Skipped != 2 {
+				t.Fatalf("unexpected stats: %+v", stats)
+			}
+			doc := sink.docs[0]
+			if doc.Title != "Raft (algorithm)" || doc.URL != "https://en.wikipedia.org/wiki/Raft_%28algorithm%29" {
+				t.Fatalf("unexpected document identity: %s %s", doc.Title, doc.URL)
+			}
+			if doc.FetchedAt.IsZero() {
+				t.Fatalf("expected revision timestamp to be recorded")
+			}
+		})
+	}
+}
+
+func TestStripWikitext(t *testing.T) {
+	raw := `{{Infobox|name={{nested}}}}'''Raft''' is a [[consensus (computer science)|consensus]] algorithm.<ref name="a">cite</ref>
+[[File:Diagram.png|thumb|A [[leader]] election]]
+== Leader election ==
+* A [[node]] wins a [https://example.com majority] vote &amp; leads.
+{| class="wikitable"
+| cell
+|}
+[[Category:Algorithms]]`
+	got := importer.StripWikitext(raw)
+	want := "Raft is a consensus algorithm. Leader election A node wins a majority vote & leads."
+	if got != want {
+		t.Fatalf("unexpected plain text:\n got: %q\nwant: %q", got, want)
+	}
+	if strings.Contains(got, "cite") || strings.Contains(got, "Diagram") {
+		t.Fatalf("expected references and media to be removed: %q", got)
+	}
+}
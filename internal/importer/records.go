@@ -0,0 +1,347 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/crawler"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// Record is a flat view of one input row keyed by column name. Nested JSON objects are
+// flattened into dotted keys such as "fields.summary".
+type Record struct {
+	Line   int
+	Fields map[string]string
+}
+
+// RecordError reports a row that could not be decoded. Importers reject the row and
+// keep reading.
+type RecordError struct {
+	Line int
+	Raw  string
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// RecordReader yields records until io.EOF.
+type RecordReader interface {
+	Next() (*Record, error)
+}
+
+// DefaultMaxLineSize is the longest JSONL line a JSONLReader decodes by default.
+const DefaultMaxLineSize = 16 * 1024 * 1024
+
+// ErrLineTooLong is reported for a JSONL line longer than the reader's MaxLineSize.
+var ErrLineTooLong = errors.New("line too long")
+
+// oversizedRawPrefix is how much of an oversized line a RecordError keeps as Raw.
+const oversizedRawPrefix = 1024
+
+// JSONLReader reads one JSON object per line.
+type JSONLReader struct {
+	// MaxLineSize is the longest line decoded. Longer lines are skipped and reported as
+	// a RecordError wrapping ErrLineTooLong, so the import rejects them and continues.
+	MaxLineSize int
+
+	reader *bufio.Reader
+	line   int
+}
+
+// NewJSONLReader creates a reader over newline-delimited JSON objects.
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	return &JSONLReader{MaxLineSize: DefaultMaxLineSize, reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next returns the next non-empty line decoded as a record.
+func (j *JSONLReader) Next() (*Record, error) {
+	for {
+		line, tooLong, err := j.readLine()
+		if err != nil {
+			return nil, err
+		}
+		j.line++
+		if tooLong {
+			return nil, &RecordError{Line: j.line, Raw: string(line), Err: fmt.Errorf("%w: more than %d bytes", ErrLineTooLong, j.MaxLineSize)}
+		}
+		raw := strings.TrimSpace(string(line))
+		if raw == "" {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(raw))
+		decoder.UseNumber()
+		var obj map[string]any
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, &RecordError{Line: j.line, Raw: raw, Err: err}
+		}
+		fields := make(map[string]string)
+		flattenJSON("", obj, fields)
+		return &Record{Line: j.line, Fields: fields}, nil
+	}
+}
+
+// readLine returns the next line, or io.EOF after the last one. A line longer than
+// MaxLineSize is read to its end but only its first bytes are kept, with tooLong set.
+func (j *JSONLReader) readLine() (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := j.reader.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(bytes.TrimRight(line, "\r\n")) > j.MaxLineSize {
+				tooLong = true
+				line = line[:min(len(line), oversizedRawPrefix)]
+			}
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && (len(line) > 0 || tooLong):
+			return line, tooLong, nil
+		case err != nil:
+			return nil, false, err
+		}
+		return line, tooLong, nil
+	}
+}
+
+func flattenJSON(prefix string, value any, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenJSON(name, child, out)
+		}
+	case nil:
+	case string:
+		out[prefix] = v
+	case json.Number:
+		out[prefix] = v.String()
+	case bool:
+		out[prefix] = strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			out[prefix] = string(encoded)
+		}
+	}
+}
+
+// CSVReader reads comma separated rows whose first line names the columns.
+type CSVReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+// NewCSVReader reads the header row and returns a reader over the remaining rows.
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	return &CSVReader{reader: reader, header: header}, nil
+}
+
+// Next returns the next row keyed by the header columns.
+func (c *CSVReader) Next() (*Record, error) {
+	row, err := c.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RecordError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	line, _ := c.reader.FieldPos(0)
+	if len(row) != len(c.header) {
+		return nil, &RecordError{Line: line, Raw: strings.Join(row, ","), Err: fmt.Errorf("expected %d columns, got %d", len(c.header), len(row))}
+	}
+	fields := make(map[string]string, len(row))
+	for i, val := range row {
+		fields[c.header[i]] = val
+	}
+	return &Record{Line: line, Fields: fields}, nil
+}
+
+// FieldMapping maps record columns onto document fields. Content columns are joined with
+// blank lines; Metadata maps document metadata keys to columns.
+type FieldMapping struct {
+	ID         string            `json:"id"`
+	URL        string            `json:"url"`
+	Title      string            `json:"title"`
+	Content    []string          `json:"content"`
+	FetchedAt  string            `json:"fetched_at"`
+	TimeLayout string            `json:"time_layout"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// LoadFieldMapping reads a JSON field mapping from disk.
+func LoadFieldMapping(path string) (FieldMapping, error) {
+	var mapping FieldMapping
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mapping, err
+	}
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return mapping, fmt.Errorf("parse field mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// Document builds and validates a document from a record. A record needs an ID or a
+// URL, and a title or content; the ID defaults to the SHA-1 of the URL like crawled pages.
+func (m FieldMapping) Document(rec *Record) (*docs.Document, error) {
+	doc := &docs.Document{
+		ID:    strings.TrimSpace(rec.Fields[m.ID]),
+		URL:   strings.TrimSpace(rec.Fields[m.URL]),
+		Title: strings.TrimSpace(rec.Fields[m.Title]),
+		Type:  docs.TypePage,
+	}
+
+	parts := make([]string, 0, len(m.Content))
+	for _, column := range m.Content {
+		if val := strings.TrimSpace(rec.Fields[column]); val != "" {
+			parts = append(parts, val)
+		}
+	}
+	doc.Content = strings.Join(parts, "\n\n")
+
+	if doc.URL != "" {
+		parsed, err := url.Parse(doc.URL)
+		if err != nil || parsed.Scheme == "" {
+			return nil, fmt.Errorf("invalid url %q", doc.URL)
+		}
+	}
+	if doc.ID == "" {
+		if doc.URL == "" {
+			return nil, errors.New("record has neither id nor url")
+		}
+		sum := sha1.Sum([]byte(doc.URL))
+		doc.ID = fmt.Sprintf("%x", sum[:])
+	}
+	if doc.Title == "" && doc.Content == "" {
+		return nil, errors.New("record has neither title nor content")
+	}
+
+	if raw := strings.TrimSpace(rec.Fields[m.FetchedAt]); m.FetchedAt != "" && raw != "" {
+		layout := m.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		ts, err := time.Parse(layout, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s timestamp %q: %w", m.FetchedAt, raw, err)
+		}
+		doc.FetchedAt = ts
+	}
+
+	for key, column := range m.Metadata {
+		if val, ok := rec.Fields[column]; ok && val != "" {
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]string, len(m.Metadata))
+			}
+			doc.Metadata[key] = val
+		}
+	}
+	return doc, nil
+}
+
+// RecordImportOptions controls a bulk record import.
+type RecordImportOptions struct {
+	Mapping FieldMapping
+	// Rejects receives one JSON line per rejected record with its line number and reason.
+	Rejects io.Writer
+	// ProgressEvery logs progress after this many records read; zero disables it.
+	ProgressEvery int
+	Logger        telemetry.Logger
+}
+
+type rejectedRecord struct {
+	Line   int               `json:"line"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Raw    string            `json:"raw,omitempty"`
+}
+
+// ImportRecords validates each record against the mapping and publishes valid documents
+// to sink. Invalid records are written to opts.Rejects. The caller owns the sink.
+func ImportRecords(ctx context.Context, reader RecordReader, sink crawler.DocumentSink, opts RecordImportOptions) (ImportStats, error) {
+	var stats ImportStats
+	var rejects *json.Encoder
+	if opts.Rejects != nil {
+		rejects = json.NewEncoder(opts.Rejects)
+	}
+	reject := func(entry rejectedRecord) error {
+		stats.Rejected++
+		if rejects == nil {
+			return nil
+		}
+		return rejects.Encode(entry)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			stats.Read++
+			if err := reject(rejectedRecord{Line: recErr.Line, Error: recErr.Err.Error(), Raw: recErr.Raw}); err != nil {
+				return stats, err
+			}
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Read++
+
+		doc, err := opts.Mapping.Document(rec)
+		if err != nil {
+			if err := reject(rejectedRecord{Line: rec.Line, Error: err.Error(), Fields: rec.Fields}); err != nil {
+				return stats, err
+			}
+		} else {
+			sink.Consume(doc)
+			stats.Published++
+		}
+
+		if opts.Logger != nil && opts.ProgressEvery > 0 && stats.Read%opts.ProgressEvery == 0 {
+			opts.Logger.Info("import_progress", "read", stats.Read, "published", stats.Published, "rejected", stats.Rejected)
+		}
+	}
+}
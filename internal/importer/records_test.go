@@ -0,0 +1,120 @@
+package importer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/importer"
+)
+
+func TestImportRecordsFromJSONL(t *testing.T) {
+	input := strings.Join([]string{
+		`{"key": "OPS-1", "summary": "Kafka lag alert", "fields": {"description": "Consumer lag above threshold", "status": "open"}, "updated": "2024-05-01T10:00:00Z"}`,
+		`{"key": "", "summary": "No identifier"}`,
+		`{not json}`,
+		`{"key": "OPS-2", "summary": "Bad time", "updated": "yesterday"}`,
+	}, "\n")
+	mapping := importer.FieldMapping{
+		ID:        "key",
+		Title:     "summary",
+		Content:   []string{"fields.description"},
+		FetchedAt: "updated",
+		Metadata:  map[string]string{"status": "fields.status"},
+	}
+
+	sink := &collectingSink{}
+	var rejects bytes.Buffer
+	stats, err := importer.ImportRecords(context.Background(), importer.NewJSONLReader(strings.NewReader(input)), sink, importer.RecordImportOptions{Mapping: mapping, Rejects: &rejects})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if stats.Read != 4 || stats.Published != 1 || stats.Rejected != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	doc := sink.docs[0]
+	if doc.ID != "OPS-1" || doc.Content != "Consumer lag above threshold" || doc.Metadata["status"] != "open" || doc.FetchedAt.IsZero() {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if lines := strings.Count(rejects.String(), "\n"); lines != 3 {
+		t.Fatalf("expected 3 rejected lines, got %d:\n%s", lines, rejects.String())
+	}
+	if !strings.Contains(rejects.String(), `"line":3`) {
+		t.Fatalf("expected rejected record to carry its line number:\n%s", rejects.String())
+	}
+}
+
+func TestImportRecordsRejectsOversizedJSONLLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"key": "OPS-1", "summary": "Before"}`,
+		`{"key": "OPS-2", "summary": "` + strings.Repeat("x", 200*1024) + `"}`,
+		`{"key": "OPS-3", "summary": "After"}`,
+	}, "\n")
+	reader := importer.NewJSONLReader(strings.NewReader(input))
+	reader.MaxLineSize = 1024
+
+	sink := &collectingSink{}
+	var rejects bytes.Buffer
+	stats, err := importer.ImportRecords(context.Background(), reader, sink, importer.RecordImportOptions{Mapping: importer.FieldMapping{ID: "key", Title: "summary"}, Rejects: &rejects})
+	if err != nil {
+		t.Fatalf("expected an oversized line to be rejected, not to abort the import: %v", err)
+	}
+	if stats.Published != 2 || stats.Rejected != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if sink.docs[1].ID != "OPS-3" {
+		t.Fatalf("expected the line after the oversized one to be imported, got %+v", sink.docs[1])
+	}
+	if !strings.Contains(rejects.String(), `"line":2`) || !strings.Contains(rejects.String(), "line too long") || rejects.Len() > 4096 {
+		t.Fatalf("expected a short reject for line 2:\n%.200s", rejects.String())
+	}
+}
+
+func TestImportRecordsRejectsLinesOverASmallLimit(t *testing.T) {
+	input := `{"key": "OPS-1", "summary": "Short"}` + "\n" + `{"key": "OPS-2", "summary": "` + strings.Repeat("y", 300) + `"}` + "\n"
+	reader := importer.NewJSONLReader(strings.NewReader(input))
+	reader.MaxLineSize = 64
+
+	sink := &collectingSink{}
+	var rejects bytes.Buffer
+	stats, err := importer.ImportRecords(context.Background(), reader, sink, importer.RecordImportOptions{Mapping: importer.FieldMapping{ID: "key", Title: "summary"}, Rejects: &rejects})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if stats.Published != 1 || stats.Rejected != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	var rejected struct {
+		Line int    `json:"line"`
+		Raw  string `json:"raw"`
+	}
+	if err := json.Unmarshal(rejects.Bytes(), &rejected); err != nil {
+		t.Fatalf("decode reject: %v", err)
+	}
+	if rejected.Line != 2 || strings.TrimSpace(rejected.Raw) != strings.TrimSpace(strings.Split(input, "\n")[1]) {
+		t.Fatalf("expected the reject to hold exactly line 2, got %+v", rejected)
+	}
+}
+
+func TestImportRecordsFromCSV(t *testing.T) {
+	input := "url,title,body,team\nhttps://wiki.internal/runbooks/kafka,Kafka runbook,Restart brokers one at a time,platform\nnot-a-url,Broken,Body,platform\nhttps://wiki.internal/short,Too few columns\n"
+	reader, err := importer.NewCSVReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("new csv reader: %v", err)
+	}
+	mapping := importer.FieldMapping{URL: "url", Title: "title", Content: []string{"body"}, Metadata: map[string]string{"team": "team"}}
+
+	sink := &collectingSink{}
+	stats, err := importer.ImportRecords(context.Background(), reader, sink, importer.RecordImportOptions{Mapping: mapping})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if stats.Published != 1 || stats.Rejected != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if doc := sink.docs[0]; doc.ID == "" || doc.URL != "https://wiki.internal/runbooks/kafka" || doc.Metadata["team"] != "platform" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
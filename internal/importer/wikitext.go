@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	wikiCommentRe   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	wikiRefRe       = regexp.MustCompile(`(?is)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	wikiExtLinkRe   = regexp.MustCompile(`\[(?:https?|ftp)://[^\s\]]+(?:\s+([^\]]*))?\]`)
+	wikiHeadingRe   = regexp.MustCompile(`(?m)^=+\s*(.*?)\s*=+\s*$`)
+	wikiTagRe       = regexp.MustCompile(`<[^>]+>`)
+	wikiMagicRe     = regexp.MustCompile(`__[A-Z]+__`)
+	wikiListRe      = regexp.MustCompile(`(?m)^[*#:;]+\s*`)
+	wikiEmphasisRep = strings.NewReplacer("'''''", "", "'''", "", "''", "")
+)
+
+// hiddenLinkNamespaces lists internal link prefixes whose targets carry no readable text.
+var hiddenLinkNamespaces = []string{"file:", "image:", "media:", "category:"}
+
+// StripWikitext converts MediaWiki markup into plain text. It is intentionally basic:
+// templates, tables, references, and media links are dropped, while links keep their
+// visible label.
+func StripWikitext(text string) string {
+	text = wikiCommentRe.ReplaceAllString(text, "")
+	text = wikiRefRe.ReplaceAllString(text, "")
+	text = removeBalanced(text, "{{", "}}")
+	text = removeBalanced(text, "{|", "|}")
+	text = replaceInternalLinks(text)
+	text = wikiExtLinkRe.ReplaceAllString(text, "$1")
+	text = wikiEmphasisRep.Replace(text)
+	text = wikiHeadingRe.ReplaceAllString(text, "$1")
+	text = wikiTagRe.ReplaceAllString(text, "")
+	text = wikiMagicRe.ReplaceAllString(text, "")
+	text = wikiListRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// removeBalanced drops every region delimited by open and close, honoring nesting.
+func removeBalanced(text, open, close string) string {
+	if !strings.Contains(text, open) {
+		return text
+	}
+	var sb strings.Builder
+	depth := 0
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], open):
+			depth++
+			i += len(open)
+		case depth > 0 && strings.HasPrefix(text[i:], close):
+			depth--
+			i += len(close)
+		default:
+			if depth == 0 {
+				sb.WriteByte(text[i])
+			}
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// replaceInternalLinks rewrites [[target|label]] to its label and [[target]] to its
+// target, dropping file and category links entirely.
+func replaceInternalLinks(text string) string {
+	if !strings.Contains(text, "[[") {
+		return text
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		if !strings.HasPrefix(text[i:], "[[") {
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+		end := matchingLinkEnd(text, i)
+		if end < 0 {
+			sb.WriteString(text[i:])
+			break
+		}
+		inner := text[i+2 : end]
+		i = end + 2
+
+		lower := strings.ToLower(strings.TrimSpace(inner))
+		hidden := false
+		for _, ns := range hiddenLinkNamespaces {
+			if strings.HasPrefix(lower, ns) {
+				hidden = true
+				break
+			}
+		}
+		if hidden {
+			continue
+		}
+		if pipe := strings.LastIndex(inner, "|"); pipe >= 0 {
+			inner = inner[pipe+1:]
+		}
+		sb.WriteString(replaceInternalLinks(inner))
+	}
+	return sb.String()
+}
+
+// matchingLinkEnd returns the index of the "]]" closing the "[[" at start, or -1.
+func matchingLinkEnd(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text)-1; {
+		switch {
+		case strings.HasPrefix(text[i:], "[["):
+			depth++
+			i += 2
+		case strings.HasPrefix(text[i:], "]]"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+			i += 2
+		default:
+			i++
+		}
+	}
+	return -1
+}
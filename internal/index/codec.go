@@ -0,0 +1,102 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errShortBuffer reports a snapshot section that ends before its contents do.
+var errShortBuffer = errors.New("unexpected end of section")
+
+// sectionEncoder appends variable-length integers and length-prefixed strings.
+type sectionEncoder struct {
+	buf []byte
+}
+
+func (e *sectionEncoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *sectionEncoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *sectionEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *sectionEncoder) strings(values []string) {
+	e.uvarint(uint64(len(values)))
+	for _, s := range values {
+		e.string(s)
+	}
+}
+
+// sectionDecoder reads values written by sectionEncoder. The first failure is sticky
+// so callers can decode a whole record and check err once.
+type sectionDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *sectionDecoder) fail() {
+	if d.err == nil {
+		d.err = errShortBuffer
+	}
+	d.data = nil
+}
+
+func (d *sectionDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *sectionDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// count reads a length and rejects values that cannot fit in the remaining data,
+// which keeps corrupt input from triggering huge allocations.
+func (d *sectionDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *sectionDecoder) bytes() []byte {
+	n := d.count()
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *sectionDecoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *sectionDecoder) strings() []string {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+	values := make([]string, n)
+	for i := range values {
+		values[i] = d.string()
+	}
+	return values
+}
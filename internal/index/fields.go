@@ -0,0 +1,97 @@
+package index
+
+import (
+	"strings"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+// Field names indexed by default.
+const (
+	FieldTitle = "title"
+	FieldURL   = "url"
+	FieldBody  = "body"
+)
+
+// Field describes a document field indexed with its own postings and lengths so that
+// scorers can weight matches per field. Text returns the raw field text, which the
+// index analyzer turns into terms.
+type Field struct {
+	Name string
+	Text func(doc *docs.Document) string
+}
+
+// DefaultFields returns the title, URL, and body fields.
+func DefaultFields() []Field {
+	return []Field{
+		{Name: FieldTitle, Text: func(doc *docs.Document) string { return doc.Title }},
+		{Name: FieldURL, Text: func(doc *docs.Document) string { return URLText(doc.URL) }},
+		bodyField(),
+	}
+}
+
+func bodyField() Field {
+	return Field{Name: FieldBody, Text: func(doc *docs.Document) string { return doc.Content }}
+}
+
+// URLText strips the scheme and "www." prefix from a URL, which carry no signal.
+func URLText(raw string) string {
+	if idx := strings.Index(raw, "://"); idx >= 0 {
+		raw = raw[idx+3:]
+	}
+	return strings.TrimPrefix(raw, "www.")
+}
+
+// fieldIndex holds the postings and length statistics of a single field.
+type fieldIndex struct {
+	postings    map[string]map[string]*Posting
+	docLengths  map[string]int
+	docTerms    map[string][]string
+	totalTokens int
+}
+
+func newFieldIndex() *fieldIndex {
+	return &fieldIndex{
+		postings:   make(map[string]map[string]*Posting),
+		docLengths: make(map[string]int),
+		docTerms:   make(map[string][]string),
+	}
+}
+
+// add indexes the tokens of one document at the analyzer's positions, which count
+// removed stopwords, and returns the distinct terms it contains.
+func (f *fieldIndex) add(docID string, tokens []string, positions []int) []string {
+	termPositions := make(map[string][]int)
+	for i, token := range tokens {
+		termPositions[token] = append(termPositions[token], positions[i])
+	}
+
+	terms := make([]string, 0, len(termPositions))
+	for term, positions := range termPositions {
+		terms = append(terms, term)
+		postingList, ok := f.postings[term]
+		if !ok {
+			postingList = make(map[string]*Posting)
+			f.postings[term] = postingList
+		}
+		postingList[docID] = &Posting{DocID: docID, TF: float64(len(positions)), Positions: positions}
+	}
+	f.docLengths[docID] = len(tokens)
+	f.docTerms[docID] = terms
+	f.totalTokens += len(tokens)
+	return terms
+}
+
+func (f *fieldIndex) remove(docID string) {
+	for _, term := range f.docTerms[docID] {
+		if postingList, ok := f.postings[term]; ok {
+			delete(postingList, docID)
+			if len(postingList) == 0 {
+				delete(f.postings, term)
+			}
+		}
+	}
+	f.totalTokens -= f.docLengths[docID]
+	delete(f.docLengths, docID)
+	delete(f.docTerms, docID)
+}
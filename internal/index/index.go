@@ -1,144 +1,388 @@
 package index
 
 import (
-	"sort"
 	"sync"
+	"sync/atomic"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
 )
 
+// DefaultBufferSize is the number of buffered documents that triggers a segment freeze.
+const DefaultBufferSize = 1000
+
 // Posting represents a term occurrence in a document.
 type Posting struct {
 	DocID string
 	TF    float64
+	// Positions lists the token positions of the term within the document in ascending
+	// order. Tokens the analyzer removed, such as stopwords, still count.
+	Positions []int
 }
 
-// InvertedIndex stores postings lists and document statistics.
+// InvertedIndex stores per-field postings lists and document statistics.
+//
+// New documents go into a small in-memory buffer that is frozen into an immutable
+// segment once it holds BufferSize documents, or when Refresh is called, which RunMerger
+// does on every tick. Readers work on an atomically published set of segments and never
+// wait for the writer lock: a write is visible to the next reader through a read-only
+// copy of the buffer, which is shared by readers until the buffer changes again.
+// RunMerger also compacts segments in the background.
 type InvertedIndex struct {
-	mu          sync.RWMutex
-	documents   map[string]*docs.Document
-	postings    map[string]map[string]*Posting
-	docLengths  map[string]int
-	docTerms    map[string][]string
-	totalTokens int
+	// BufferSize is the number of buffered documents that triggers a freeze.
+	BufferSize int
+	// MergePolicy decides which segments are merged in the background.
+	MergePolicy MergePolicy
+	// Analyzer turns field text into terms. Queries against the index must be analyzed
+	// the same way. Set it before adding documents.
+	Analyzer analysis.Analyzer
+
+	fields     []Field
+	fieldNames []string
+
+	mu        sync.Mutex
+	buffer    *writeBuffer
+	locations map[string]*segment
+	// replaced holds the segment copies of documents whose new version is still in the
+	// buffer. They are deleted by the freeze that publishes the new version, so an update
+	// never leaves the document missing.
+	replaced map[string]*segment
+	// children maps a parent ID to the IDs of the indexed documents derived from it.
+	children map[string]map[string]struct{}
+	dirty    atomic.Bool
+	// current holds the frozen segments; visible adds a copy of the buffer for readers,
+	// and stale reports that the buffer changed since visible was published.
+	current atomic.Pointer[segmentSet]
+	visible atomic.Pointer[segmentSet]
+	stale   atomic.Bool
+	nextID  atomic.Uint64
+	// version counts published segment sets; it is only changed under mu.
+	version uint64
+
+	mergeMu     sync.Mutex
+	mergeSignal chan struct{}
 }
 
-// NewInvertedIndex constructs an empty index.
+// NewInvertedIndex constructs an empty index over the default fields.
 func NewInvertedIndex() *InvertedIndex {
-	return &InvertedIndex{
-		documents:  make(map[string]*docs.Document),
-		postings:   make(map[string]map[string]*Posting),
-		docLengths: make(map[string]int),
-		docTerms:   make(map[string][]string),
+	return NewFieldedIndex(DefaultFields()...)
+}
+
+// NewFieldedIndex constructs an empty index over the provided fields. The body field
+// always exists because phrase, snippet, and semantic features depend on it.
+func NewFieldedIndex(fields ...Field) *InvertedIndex {
+	idx := &InvertedIndex{
+		BufferSize:  DefaultBufferSize,
+		MergePolicy: DefaultMergePolicy(),
+		Analyzer:    analysis.Standard(),
+		locations:   make(map[string]*segment),
+		replaced:    make(map[string]*segment),
+		children:    make(map[string]map[string]struct{}),
+		mergeSignal: make(chan struct{}, 1),
 	}
+	hasBody := false
+	for _, field := range fields {
+		idx.fields = append(idx.fields, field)
+		hasBody = hasBody || field.Name == FieldBody
+	}
+	if !hasBody {
+		idx.fields = append(idx.fields, bodyField())
+	}
+	for _, field := range idx.fields {
+		idx.fieldNames = append(idx.fieldNames, field.Name)
+	}
+	idx.buffer = newWriteBuffer(idx.fields)
+	set := newSegmentSet(idx.fieldNames, nil)
+	idx.current.Store(set)
+	idx.visible.Store(set)
+	return idx
 }
 
-// AddDocument tokenizes and inserts the document into the index.
+// AddDocument analyzes each field and inserts the document into the write buffer,
+// replacing any earlier version with the same ID. doc.Tokens is set to the body terms.
 func (idx *InvertedIndex) AddDocument(doc *docs.Document) {
-	tokens := doc.Tokens
-	if len(tokens) == 0 {
-		tokens = Tokenize(doc.Content)
-		doc.Tokens = tokens
+	fieldTokens := make([][]string, len(idx.fields))
+	fieldPositions := make([][]int, len(idx.fields))
+	for i, field := range idx.fields {
+		fieldTokens[i], fieldPositions[i] = idx.Analyzer.AnalyzePositions(field.Text(doc))
+		if field.Name == FieldBody {
+			doc.Tokens = fieldTokens[i]
+		}
 	}
 
-	termCounts := make(map[string]int)
-	for _, token := range tokens {
-		termCounts[token]++
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.replaceLocked(doc.ID)
+	idx.buffer.add(doc, idx.fields, fieldTokens, fieldPositions)
+	idx.linkLocked(doc)
+	idx.dirty.Store(true)
+	idx.stale.Store(true)
+	if size := idx.BufferSize; size > 0 && len(idx.buffer.documents) >= size {
+		idx.freezeLocked()
 	}
+}
 
+// RemoveDocument deletes a document and its postings, reporting whether it was indexed.
+func (idx *InvertedIndex) RemoveDocument(id string) bool {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
+	return idx.removeLocked(id)
+}
 
-	if existingTerms, ok := idx.docTerms[doc.ID]; ok {
-		for _, term := range existingTerms {
-			if postingList, ok := idx.postings[term]; ok {
-				delete(postingList, doc.ID)
-				if len(postingList) == 0 {
-					delete(idx.postings, term)
-				}
-			}
+// RemoveByParent deletes every document derived from the given parent, such as the
+// images of a page, and returns how many were removed.
+func (idx *InvertedIndex) RemoveByParent(parentID string) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	removed := 0
+	for id := range idx.children[parentID] {
+		if idx.removeLocked(id) {
+			removed++
 		}
-		if length, ok := idx.docLengths[doc.ID]; ok {
-			idx.totalTokens -= length
+	}
+	return removed
+}
+
+// linkLocked records doc under its parent so RemoveByParent can find it.
+func (idx *InvertedIndex) linkLocked(doc *docs.Document) {
+	if doc.ParentID == "" {
+		return
+	}
+	ids, ok := idx.children[doc.ParentID]
+	if !ok {
+		ids = make(map[string]struct{})
+		idx.children[doc.ParentID] = ids
+	}
+	ids[doc.ID] = struct{}{}
+}
+
+func (idx *InvertedIndex) unlinkLocked(doc *docs.Document) {
+	ids, ok := idx.children[doc.ParentID]
+	if !ok {
+		return
+	}
+	delete(ids, doc.ID)
+	if len(ids) == 0 {
+		delete(idx.children, doc.ParentID)
+	}
+}
+
+// replaceLocked unlinks the indexed version of a document that is about to be added
+// again. A buffered version is dropped; a segment version stays visible until the new
+// version is frozen.
+func (idx *InvertedIndex) replaceLocked(id string) {
+	if doc, ok := idx.buffer.documents[id]; ok {
+		idx.buffer.remove(id)
+		idx.unlinkLocked(doc)
+		return
+	}
+	if seg, ok := idx.locations[id]; ok {
+		delete(idx.locations, id)
+		idx.unlinkLocked(seg.documents[seg.ordinals[id]])
+		idx.replaced[id] = seg
+	}
+}
+
+// removeLocked drops a buffered document or marks a segment document deleted. Deletes
+// from segments are published immediately.
+func (idx *InvertedIndex) removeLocked(id string) bool {
+	removed := false
+	if doc, ok := idx.buffer.documents[id]; ok {
+		idx.buffer.remove(id)
+		idx.unlinkLocked(doc)
+		idx.stale.Store(true)
+		removed = true
+	}
+	seg, ok := idx.replaced[id]
+	if ok {
+		delete(idx.replaced, id)
+	} else if seg, ok = idx.locations[id]; ok {
+		delete(idx.locations, id)
+		idx.unlinkLocked(seg.documents[seg.ordinals[id]])
+	}
+	if ok {
+		idx.publishLocked(withDeletes(idx.current.Load().refs, map[string]*segment{id: seg}))
+		removed = true
+	}
+	return removed
+}
+
+// withDeletes returns refs with the given documents marked deleted in their segments.
+func withDeletes(refs []*segmentRef, deletes map[string]*segment) []*segmentRef {
+	bySegment := make(map[*segment][]string)
+	for id, seg := range deletes {
+		bySegment[seg] = append(bySegment[seg], id)
+	}
+	next := make([]*segmentRef, len(refs), len(refs)+1)
+	for i, ref := range refs {
+		if ids, ok := bySegment[ref.seg]; ok {
+			ref = ref.withDeleted(ids...)
 		}
+		next[i] = ref
 	}
+	return next
+}
 
-	totalTerms := 0
-	termsOrdered := make([]string, 0, len(termCounts))
-	for term, count := range termCounts {
-		totalTerms += count
-		termsOrdered = append(termsOrdered, term)
-		postingList, ok := idx.postings[term]
-		if !ok {
-			postingList = make(map[string]*Posting)
-			idx.postings[term] = postingList
+// Refresh freezes buffered documents into a new segment so readers can see them.
+func (idx *InvertedIndex) Refresh() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.freezeLocked()
+}
+
+func (idx *InvertedIndex) freezeLocked() {
+	idx.dirty.Store(false)
+	if len(idx.buffer.documents) == 0 {
+		return
+	}
+	seg := idx.buffer.freeze(idx.nextID.Add(1), idx.fieldNames)
+	idx.buffer = newWriteBuffer(idx.fields)
+	for _, id := range seg.docIDs {
+		idx.locations[id] = seg
+	}
+	refs := withDeletes(idx.current.Load().refs, idx.replaced)
+	refs = append(refs, newSegmentRef(seg))
+	clear(idx.replaced)
+	idx.publishLocked(refs)
+
+	select {
+	case idx.mergeSignal <- struct{}{}:
+	default:
+	}
+}
+
+// publishLocked makes refs the frozen segments of the index. Readers see them at once
+// when the buffer is empty, and together with a copy of the buffer otherwise.
+func (idx *InvertedIndex) publishLocked(refs []*segmentRef) {
+	set := idx.newSetLocked(refs)
+	idx.current.Store(set)
+	telemetry.SetIndexSegments(len(refs))
+	telemetry.SetIndexPostingBytes(set.postingBytes, set.rawBytes)
+	if len(idx.buffer.documents) == 0 {
+		idx.visible.Store(set)
+		idx.stale.Store(false)
+		return
+	}
+	idx.stale.Store(true)
+}
+
+func (idx *InvertedIndex) newSetLocked(refs []*segmentRef) *segmentSet {
+	set := newSegmentSet(idx.fieldNames, refs)
+	idx.version++
+	set.version = idx.version
+	return set
+}
+
+// publishBufferLocked publishes the frozen segments plus a read-only copy of the
+// buffer, with the segment versions of replaced documents hidden. The copy is not a
+// segment of the index; the buffer keeps filling until it is frozen.
+func (idx *InvertedIndex) publishBufferLocked() {
+	idx.stale.Store(false)
+	if len(idx.buffer.documents) == 0 {
+		idx.visible.Store(idx.current.Load())
+		return
+	}
+	refs := withDeletes(idx.current.Load().refs, idx.replaced)
+	refs = append(refs, newSegmentRef(idx.buffer.freeze(0, idx.fieldNames)))
+	idx.visible.Store(idx.newSetLocked(refs))
+}
+
+// Reader returns a consistent point-in-time view of the index that includes every
+// completed write. While a writer holds the index lock, the reader gets the last
+// published view instead of waiting.
+func (idx *InvertedIndex) Reader() *Reader {
+	if idx.stale.Load() && idx.mu.TryLock() {
+		if idx.stale.Load() {
+			idx.publishBufferLocked()
 		}
-		postingList[doc.ID] = &Posting{DocID: doc.ID, TF: float64(count)}
+		idx.mu.Unlock()
 	}
+	return &Reader{set: idx.visible.Load()}
+}
+
+// frozenReader returns a reader over the frozen segments only, after freezing the buffer.
+func (idx *InvertedIndex) frozenReader() *Reader {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.freezeLocked()
+	return &Reader{set: idx.current.Load()}
+}
 
-	idx.documents[doc.ID] = doc
-	idx.docLengths[doc.ID] = len(tokens)
-	idx.docTerms[doc.ID] = termsOrdered
-	idx.totalTokens += totalTerms
+// PostingBytes returns the compressed size of all published postings lists and an
+// estimate of what they would occupy as uncompressed maps of postings.
+func (idx *InvertedIndex) PostingBytes() (compressed, uncompressed int) {
+	set := idx.Reader().set
+	return set.postingBytes, set.rawBytes
+}
+
+// SegmentCount returns the number of frozen segments.
+func (idx *InvertedIndex) SegmentCount() int {
+	return len(idx.current.Load().refs)
 }
 
 // Document retrieves a stored document by ID.
 func (idx *InvertedIndex) Document(id string) (*docs.Document, bool) {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	d, ok := idx.documents[id]
-	return d, ok
+	return idx.Reader().Document(id)
+}
+
+// FieldNames returns the indexed field names in configuration order.
+func (idx *InvertedIndex) FieldNames() []string {
+	return append([]string(nil), idx.fieldNames...)
 }
 
-// Postings returns a copy of the postings list for a term.
+// Postings returns a copy of the body postings list for a term.
 func (idx *InvertedIndex) Postings(term string) []Posting {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	entry, ok := idx.postings[term]
-	if !ok {
-		return nil
-	}
-	results := make([]Posting, 0, len(entry))
-	for _, posting := range entry {
-		results = append(results, *posting)
-	}
-	return results
+	return idx.Reader().Postings(term)
+}
+
+// FieldPostings returns a copy of the postings list for a term within one field.
+func (idx *InvertedIndex) FieldPostings(field, term string) []Posting {
+	return idx.Reader().FieldPostings(field, term)
+}
+
+// Posting returns the body posting of a term for a single document.
+func (idx *InvertedIndex) Posting(term, docID string) (Posting, bool) {
+	return idx.Reader().Posting(term, docID)
 }
+
+// FieldPosting returns the posting of a term for a single document within one field.
+func (idx *InvertedIndex) FieldPosting(field, term, docID string) (Posting, bool) {
+	return idx.Reader().FieldPosting(field, term, docID)
+}
+
+// DocumentFrequency returns the number of documents containing the term in any field.
 func (idx *InvertedIndex) DocumentFrequency(term string) int {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	return len(idx.postings[term])
+	return idx.Reader().DocumentFrequency(term)
+}
+
+// FieldDocumentFrequency returns the number of documents containing the term in one field.
+func (idx *InvertedIndex) FieldDocumentFrequency(field, term string) int {
+	return idx.Reader().FieldDocumentFrequency(field, term)
 }
 
 // DocumentCount returns the number of indexed documents.
 func (idx *InvertedIndex) DocumentCount() int {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	return len(idx.documents)
+	return idx.Reader().DocumentCount()
 }
 
-// AverageDocumentLength returns the average token length across indexed documents.
+// AverageDocumentLength returns the average body token length across indexed documents.
 func (idx *InvertedIndex) AverageDocumentLength() float64 {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	if len(idx.docLengths) == 0 {
-		return 0
-	}
-	return float64(idx.totalTokens) / float64(len(idx.docLengths))
+	return idx.Reader().AverageDocumentLength()
+}
+
+// AverageFieldLength returns the average token length of a field across indexed documents.
+func (idx *InvertedIndex) AverageFieldLength(field string) float64 {
+	return idx.Reader().AverageFieldLength(field)
+}
+
+// FieldLength returns the token length of a field for one document.
+func (idx *InvertedIndex) FieldLength(field, docID string) int {
+	return idx.Reader().FieldLength(field, docID)
 }
 
 // Documents returns all documents sorted by ID for deterministic ordering.
 func (idx *InvertedIndex) Documents() []*docs.Document {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	ids := make([]string, 0, len(idx.documents))
-	for id := range idx.documents {
-		ids = append(ids, id)
-	}
-	sort.Strings(ids)
-	result := make([]*docs.Document, 0, len(ids))
-	for _, id := range ids {
-		result = append(result, idx.documents[id])
-	}
-	return result
+	return idx.Reader().Documents()
 }
@@ -1,6 +1,8 @@
 package index_test
 
 import (
+	"fmt"
+	"runtime"
 	"testing"
 
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
@@ -38,3 +40,323 @@ func TestTokenize(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderTermsAndVersion(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "a", Title: "Kafka", Content: "kafka streams"})
+	idx.AddDocument(&docs.Document{ID: "b", Content: "kafka raft"})
+	first := idx.Reader()
+	want := []index.TermFrequency{{Term: "kafka", DocFreq: 2}, {Term: "raft", DocFreq: 1}, {Term: "streams", DocFreq: 1}}
+	if got := first.Terms(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected terms %v, got %v", want, got)
+	}
+
+	idx.RemoveDocument("b")
+	second := idx.Reader()
+	if second.Version() <= first.Version() {
+		t.Fatalf("expected version to advance after a delete, got %d then %d", first.Version(), second.Version())
+	}
+	want = []index.TermFrequency{{Term: "kafka", DocFreq: 1}, {Term: "streams", DocFreq: 1}}
+	if got := second.Terms(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected deleted terms to disappear, got %v", got)
+	}
+}
+
+func TestShardedIndexRoutesAndMergesStats(t *testing.T) {
+	sharded := index.NewShardedIndex(4)
+	single := index.NewInvertedIndex()
+	for i := 0; i < 40; i++ {
+		doc := &docs.Document{ID: fmt.Sprintf("doc-%d", i), Title: "kafka", Content: fmt.Sprintf("kafka partition %d", i%3)}
+		sharded.AddDocument(doc)
+		single.AddDocument(&docs.Document{ID: doc.ID, Title: doc.Title, Content: doc.Content})
+	}
+	used := 0
+	for _, shard := range sharded.Shards() {
+		if shard.DocumentCount() > 0 {
+			used++
+		}
+	}
+	if used != 4 || sharded.DocumentCount() != 40 {
+		t.Fatalf("expected documents spread over 4 shards, got %d shards and %d documents", used, sharded.DocumentCount())
+	}
+	if _, ok := sharded.Shards()[index.ShardOf("doc-7", 4)].Document("doc-7"); !ok {
+		t.Fatalf("expected doc-7 in the shard ShardOf names")
+	}
+
+	var stats index.Stats
+	for _, r := range sharded.Readers() {
+		stats.Merge(r.Stats([]string{"kafka", "1"}))
+	}
+	want := single.Reader()
+	if stats.DocumentCount() != 40 || stats.DocumentFrequency("1") != want.DocumentFrequency("1") ||
+		stats.FieldDocumentFrequency(index.FieldTitle, "kafka") != 40 ||
+		stats.AverageFieldLength(index.FieldBody) != want.AverageFieldLength(index.FieldBody) {
+		t.Fatalf("merged stats differ from an unsharded index: %+v", stats)
+	}
+	terms := index.MergeTerms(sharded.Readers())
+	if len(terms) != len(want.Terms()) {
+		t.Fatalf("expected %v, got %v", want.Terms(), terms)
+	}
+	for i, tf := range want.Terms() {
+		if terms[i] != tf {
+			t.Fatalf("expected %v, got %v", want.Terms(), terms)
+		}
+	}
+
+	if !sharded.RemoveDocument("doc-7") || sharded.DocumentCount() != 39 {
+		t.Fatalf("expected doc-7 to be removed from its shard")
+	}
+}
+
+func TestAddDocumentRecordsPositions(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "doc1", Content: "search the index, then search again"})
+
+	posting, ok := idx.Posting("search", "doc1")
+	if !ok {
+		t.Fatalf("expected posting for search")
+	}
+	if posting.TF != 2 || len(posting.Positions) != 2 || posting.Positions[0] != 0 || posting.Positions[1] != 4 {
+		t.Fatalf("unexpected posting: %+v", posting)
+	}
+}
+
+func TestFieldPostingsAndReindexing(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "doc1", Title: "Kafka Guide", URL: "https://www.example.com/kafka-guide", Content: "Brokers and partitions."})
+
+	if got := idx.FieldPostings(index.FieldTitle, "kafka"); len(got) != 1 {
+		t.Fatalf("expected title posting for kafka, got %v", got)
+	}
+	if got := idx.FieldDocumentFrequency(index.FieldURL, "www"); got != 0 {
+		t.Fatalf("expected www to be stripped from url tokens")
+	}
+	if got := idx.DocumentFrequency("kafka"); got != 1 {
+		t.Fatalf("expected kafka in one document across fields, got %d", got)
+	}
+
+	idx.AddDocument(&docs.Document{ID: "doc1", Title: "Pulsar Guide", URL: "https://example.com/pulsar", Content: "Brokers and bookies."})
+	if got := idx.DocumentFrequency("kafka"); got != 0 {
+		t.Fatalf("expected reindexing to drop stale terms, got df %d", got)
+	}
+	if got := idx.FieldLength(index.FieldTitle, "doc1"); got != 2 {
+		t.Fatalf("expected title length 2, got %d", got)
+	}
+}
+
+func TestRemoveDocument(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "page", Content: "kafka brokers"})
+	idx.AddDocument(&docs.Document{ID: "img1", ParentID: "page", Content: "kafka diagram"})
+	idx.AddDocument(&docs.Document{ID: "img2", ParentID: "other", Content: "kafka logo"})
+
+	if !idx.RemoveDocument("page") || idx.RemoveDocument("page") {
+		t.Fatalf("expected the first removal to succeed and the second to be a no-op")
+	}
+	if removed := idx.RemoveByParent("page"); removed != 1 {
+		t.Fatalf("expected one child removed, got %d", removed)
+	}
+	if got := idx.DocumentCount(); got != 1 {
+		t.Fatalf("expected 1 remaining document, got %d", got)
+	}
+	if got := idx.DocumentFrequency("kafka"); got != 1 {
+		t.Fatalf("expected kafka df 1 after removal, got %d", got)
+	}
+	if got := idx.Postings("brokers"); len(got) != 0 {
+		t.Fatalf("expected no postings for removed document, got %v", got)
+	}
+}
+
+func TestRemoveByParentFollowsSegmentsAndUpdates(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "img1", ParentID: "page", Content: "frozen diagram"})
+	idx.Refresh()
+	idx.AddDocument(&docs.Document{ID: "img2", ParentID: "page", Content: "buffered diagram"})
+	idx.AddDocument(&docs.Document{ID: "img3", ParentID: "page", Content: "moved diagram"})
+	idx.AddDocument(&docs.Document{ID: "img3", ParentID: "other", Content: "moved diagram"})
+
+	if removed := idx.RemoveByParent("page"); removed != 2 {
+		t.Fatalf("expected the frozen and buffered children removed, got %d", removed)
+	}
+	if removed := idx.RemoveByParent("page"); removed != 0 {
+		t.Fatalf("expected a second removal to be a no-op, got %d", removed)
+	}
+	idx.Refresh()
+	if _, ok := idx.Document("img3"); !ok || idx.DocumentCount() != 1 {
+		t.Fatalf("expected only the re-parented image to remain, got %d documents", idx.DocumentCount())
+	}
+}
+
+func TestReaderIsPointInTime(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "doc1", Content: "kafka brokers"})
+	reader := idx.Reader()
+
+	idx.AddDocument(&docs.Document{ID: "doc2", Content: "kafka partitions"})
+	idx.RemoveDocument("doc1")
+
+	if got := reader.DocumentCount(); got != 1 {
+		t.Fatalf("expected old reader to keep 1 document, got %d", got)
+	}
+	if _, ok := reader.Document("doc1"); !ok {
+		t.Fatalf("expected old reader to still see doc1")
+	}
+	current := idx.Reader()
+	if got := current.DocumentFrequency("kafka"); got != 1 {
+		t.Fatalf("expected kafka df 1 in new reader, got %d", got)
+	}
+	if _, ok := current.Document("doc2"); !ok {
+		t.Fatalf("expected new reader to see doc2")
+	}
+}
+
+func TestUpdatedDocumentStaysVisibleUntilFrozen(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "doc1", Content: "kafka brokers"})
+	idx.Refresh()
+
+	idx.AddDocument(&docs.Document{ID: "doc1", Content: "raft leaders"})
+	if got := idx.DocumentCount(); got != 1 {
+		t.Fatalf("expected the updated document to stay counted, got %d", got)
+	}
+	if doc, ok := idx.Document("doc1"); !ok || doc.Content != "raft leaders" {
+		t.Fatalf("expected the new version right after the update, got %+v, %v", doc, ok)
+	}
+	if got := idx.DocumentFrequency("kafka"); got != 0 {
+		t.Fatalf("expected the old version to be hidden, got kafka df %d", got)
+	}
+	if got := idx.SegmentCount(); got != 1 {
+		t.Fatalf("expected reads not to freeze the buffer, got %d segments", got)
+	}
+
+	idx.Refresh()
+	if got := idx.DocumentCount(); got != 1 || idx.DocumentFrequency("raft") != 1 || idx.DocumentFrequency("kafka") != 0 {
+		t.Fatalf("expected one updated document after the freeze, got count %d", got)
+	}
+	idx.AddDocument(&docs.Document{ID: "doc1", Content: "raft terms"})
+	if !idx.RemoveDocument("doc1") || idx.DocumentCount() != 0 {
+		t.Fatalf("expected removing a buffered update to remove the frozen version too")
+	}
+	idx.Refresh()
+	if got := idx.DocumentCount(); got != 0 {
+		t.Fatalf("expected no documents after the freeze, got %d", got)
+	}
+}
+
+func TestMergePurgesDeletedDocuments(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.BufferSize = 2
+	idx.MergePolicy = index.MergePolicy{SegmentsPerTier: 3, FloorSegmentSize: 2, MaxDeletedRatio: 0.3}
+
+	for i := 0; i < 6; i++ {
+		idx.AddDocument(&docs.Document{ID: string(rune('a' + i)), Title: "Segment", Content: "merge segments in the background"})
+	}
+	if got := idx.SegmentCount(); got != 3 {
+		t.Fatalf("expected 3 frozen segments, got %d", got)
+	}
+	idx.RemoveDocument("b")
+	idx.AddDocument(&docs.Document{ID: "c", Content: "updated content"})
+
+	for idx.MaybeMerge() {
+	}
+	if got := idx.SegmentCount(); got > 2 {
+		t.Fatalf("expected segments to be merged, got %d", got)
+	}
+	if got := idx.DocumentCount(); got != 5 {
+		t.Fatalf("expected 5 documents, got %d", got)
+	}
+	if got := idx.DocumentFrequency("merge"); got != 4 {
+		t.Fatalf("expected merge df 4 after delete and update, got %d", got)
+	}
+	if got := len(idx.FieldPostings(index.FieldTitle, "segment")); got != 4 {
+		t.Fatalf("expected 4 title postings, got %d", got)
+	}
+	if _, ok := idx.Posting("updated", "c"); !ok {
+		t.Fatalf("expected updated version of c to be searchable")
+	}
+	if got := idx.AverageFieldLength(index.FieldTitle); got != 0.8 {
+		t.Fatalf("expected average title length 0.8, got %v", got)
+	}
+}
+
+func TestCompressedPostingsAcrossSkipBlocks(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	for i := 0; i < 300; i++ {
+		content := "filler text"
+		if i%3 == 0 {
+			content = fmt.Sprintf("kafka %d topic and kafka", i)
+		}
+		idx.AddDocument(&docs.Document{ID: fmt.Sprintf("doc%04d", i), Content: content})
+	}
+
+	if got := len(idx.Postings("kafka")); got != 100 {
+		t.Fatalf("expected 100 kafka postings, got %d", got)
+	}
+	posting, ok := idx.Posting("kafka", "doc0297")
+	if !ok || posting.TF != 2 || posting.Positions[0] != 0 || posting.Positions[1] != 4 {
+		t.Fatalf("unexpected posting for doc0297: %+v, %v", posting, ok)
+	}
+	if _, ok := idx.Posting("kafka", "doc0298"); ok {
+		t.Fatalf("expected no kafka posting for doc0298")
+	}
+
+	compressed, uncompressed := idx.PostingBytes()
+	if compressed == 0 || compressed*3 > uncompressed {
+		t.Fatalf("expected compressed postings well below %d bytes, got %d", uncompressed, compressed)
+	}
+}
+
+func benchmarkDocuments(n int) []*docs.Document {
+	words := []string{"kafka", "broker", "partition", "consumer", "offset", "replica", "leader", "search", "index", "segment"}
+	documents := make([]*docs.Document, n)
+	for i := range documents {
+		content := ""
+		for j := 0; j < 80; j++ {
+			content += words[(i*7+j*j)%len(words)] + fmt.Sprintf(" w%d ", (i+j)%500)
+		}
+		documents[i] = &docs.Document{ID: fmt.Sprintf("doc%06d", i), Title: words[i%len(words)], Content: content}
+	}
+	return documents
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkPostingMemory reports heap used by postings held in the map-based write
+// buffer versus the same postings frozen into a compressed segment.
+func BenchmarkPostingMemory(b *testing.B) {
+	documents := benchmarkDocuments(5000)
+	for i := 0; i < b.N; i++ {
+		idx := index.NewInvertedIndex()
+		idx.BufferSize = 0
+		base := heapInUse()
+		for _, doc := range documents {
+			idx.AddDocument(doc)
+		}
+		buffered := heapInUse()
+		idx.Refresh()
+		frozen := heapInUse()
+		b.ReportMetric(float64(buffered-base)/float64(len(documents)), "buffer-B/doc")
+		b.ReportMetric(float64(frozen-base)/float64(len(documents)), "segment-B/doc")
+		compressed, _ := idx.PostingBytes()
+		b.ReportMetric(float64(compressed)/float64(len(documents)), "postings-B/doc")
+		runtime.KeepAlive(idx)
+	}
+}
+
+func BenchmarkFieldPostingSeek(b *testing.B) {
+	idx := index.NewInvertedIndex()
+	documents := benchmarkDocuments(5000)
+	for _, doc := range documents {
+		idx.AddDocument(doc)
+	}
+	reader := idx.Reader()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Posting("kafka", documents[i%len(documents)].ID)
+	}
+}
@@ -0,0 +1,148 @@
+package index
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// MergePolicy is a tiered merge policy. Segments are grouped into tiers whose live
+// document counts grow by a factor of SegmentsPerTier; once a tier holds SegmentsPerTier
+// segments its smallest members are merged into one segment of the next tier.
+type MergePolicy struct {
+	// SegmentsPerTier is both the merge width and the size ratio between tiers.
+	SegmentsPerTier int
+	// FloorSegmentSize treats smaller segments as this size so tiny segments share a tier.
+	FloorSegmentSize int
+	// MaxDeletedRatio rewrites a segment on its own once this fraction of its documents
+	// has been deleted, purging them from memory.
+	MaxDeletedRatio float64
+}
+
+// DefaultMergePolicy merges ten segments per tier and purges segments that are 30% deleted.
+func DefaultMergePolicy() MergePolicy {
+	return MergePolicy{SegmentsPerTier: 10, FloorSegmentSize: DefaultBufferSize, MaxDeletedRatio: 0.3}
+}
+
+func (p MergePolicy) tier(size int) int {
+	width := p.SegmentsPerTier
+	if width < 2 {
+		width = 2
+	}
+	limit := p.FloorSegmentSize
+	if limit < 1 {
+		limit = 1
+	}
+	tier := 0
+	for limit *= width; size >= limit; limit *= width {
+		tier++
+	}
+	return tier
+}
+
+// candidates picks the segments to merge next, or nil when the set is balanced.
+func (p MergePolicy) candidates(refs []*segmentRef) []*segmentRef {
+	width := p.SegmentsPerTier
+	if width < 2 {
+		width = 2
+	}
+	tiers := make(map[int][]*segmentRef)
+	for _, ref := range refs {
+		t := p.tier(ref.liveCount())
+		tiers[t] = append(tiers[t], ref)
+	}
+	levels := make([]int, 0, len(tiers))
+	for t := range tiers {
+		levels = append(levels, t)
+	}
+	sort.Ints(levels)
+	for _, t := range levels {
+		members := tiers[t]
+		if len(members) < width {
+			continue
+		}
+		sort.SliceStable(members, func(i, j int) bool { return members[i].liveCount() < members[j].liveCount() })
+		return members[:width]
+	}
+
+	for _, ref := range refs {
+		total := len(ref.seg.docIDs)
+		if total > 0 && float64(len(ref.deleted))/float64(total) > p.MaxDeletedRatio {
+			return []*segmentRef{ref}
+		}
+	}
+	return nil
+}
+
+// MaybeMerge performs one merge chosen by the merge policy and reports whether it did.
+// The merged segment is built without holding the writer lock; deletes that land on the
+// source segments meanwhile are carried over when it is published.
+func (idx *InvertedIndex) MaybeMerge() bool {
+	idx.mergeMu.Lock()
+	defer idx.mergeMu.Unlock()
+
+	picked := idx.MergePolicy.candidates(idx.current.Load().refs)
+	if len(picked) == 0 {
+		return false
+	}
+	merged := mergeSegments(idx.nextID.Add(1), idx.fieldNames, picked)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	sources := make(map[*segment]*segmentRef, len(picked))
+	for _, ref := range picked {
+		sources[ref.seg] = ref
+	}
+	mergedRef := newSegmentRef(merged)
+	set := idx.current.Load()
+	refs := make([]*segmentRef, 0, len(set.refs)-len(picked)+1)
+	for _, ref := range set.refs {
+		source, ok := sources[ref.seg]
+		if !ok {
+			refs = append(refs, ref)
+			continue
+		}
+		for id := range ref.deleted {
+			if _, before := source.deleted[id]; !before {
+				mergedRef = mergedRef.withDeleted(id)
+			}
+		}
+	}
+	if mergedRef.liveCount() > 0 {
+		refs = append(refs, mergedRef)
+	}
+	for _, id := range merged.docIDs {
+		if seg, ok := idx.locations[id]; ok && sources[seg] != nil {
+			idx.locations[id] = merged
+		}
+		if seg, ok := idx.replaced[id]; ok && sources[seg] != nil {
+			idx.replaced[id] = merged
+		}
+	}
+	idx.publishLocked(refs)
+	telemetry.IncIndexMerges()
+	return true
+}
+
+// RunMerger merges segments in the background until ctx is cancelled. It wakes whenever
+// a segment is frozen, and every interval freezes idle buffered documents.
+func (idx *InvertedIndex) RunMerger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-idx.mergeSignal:
+		case <-ticker.C:
+			if idx.dirty.Load() {
+				idx.Refresh()
+			}
+		}
+		for ctx.Err() == nil && idx.MaybeMerge() {
+		}
+	}
+}
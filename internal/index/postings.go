@@ -0,0 +1,131 @@
+package index
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// skipInterval is the number of postings between skip entries. Every block starts with
+// an absolute doc ordinal so the iterator can jump straight to it.
+const skipInterval = 64
+
+// mapPostingOverhead approximates the bytes an uncompressed posting costs when held as
+// map[string]*Posting: the map entry, the pointer, and the Posting struct itself.
+const mapPostingOverhead = 16 + 8 + 48
+
+// rawPosting is a decoded posting keyed by its external document ID, used while
+// building segments.
+type rawPosting struct {
+	docID     string
+	positions []int
+}
+
+// skipEntry marks the first posting of a block.
+type skipEntry struct {
+	doc    uint32
+	offset uint32
+}
+
+// postingList is a doc-ordinal sorted postings list encoded as variable-byte integers:
+// for each posting the ordinal delta, the term frequency, then the position deltas.
+type postingList struct {
+	count int
+	data  []byte
+	skips []skipEntry
+}
+
+type encodedPosting struct {
+	doc       uint32
+	positions []int
+}
+
+func encodePostings(postings []encodedPosting) *postingList {
+	sort.Slice(postings, func(i, j int) bool { return postings[i].doc < postings[j].doc })
+	list := &postingList{count: len(postings)}
+	var prev uint32
+	for i, p := range postings {
+		if i%skipInterval == 0 {
+			list.skips = append(list.skips, skipEntry{doc: p.doc, offset: uint32(len(list.data))})
+			prev = 0
+		}
+		list.data = binary.AppendUvarint(list.data, uint64(p.doc-prev))
+		list.data = binary.AppendUvarint(list.data, uint64(len(p.positions)))
+		last := 0
+		for _, pos := range p.positions {
+			list.data = binary.AppendUvarint(list.data, uint64(pos-last))
+			last = pos
+		}
+		prev = p.doc
+	}
+	return list
+}
+
+// sizeBytes returns the encoded size of the list including skip entries.
+func (l *postingList) sizeBytes() int {
+	return len(l.data) + 8*len(l.skips)
+}
+
+// postingIterator decodes a postingList in order.
+type postingIterator struct {
+	list      *postingList
+	offset    int
+	index     int
+	prev      uint32
+	doc       uint32
+	positions []int
+}
+
+func (l *postingList) iterator() *postingIterator {
+	return &postingIterator{list: l}
+}
+
+// next decodes the following posting, returning false once the list is exhausted.
+func (it *postingIterator) next() bool {
+	if it.list == nil || it.index >= it.list.count {
+		return false
+	}
+	if it.index%skipInterval == 0 {
+		it.prev = 0
+	}
+	delta := it.uvarint()
+	it.doc = it.prev + uint32(delta)
+	it.prev = it.doc
+	tf := int(it.uvarint())
+	it.positions = make([]int, tf)
+	last := 0
+	for i := range it.positions {
+		last += int(it.uvarint())
+		it.positions[i] = last
+	}
+	it.index++
+	return true
+}
+
+// advance moves to the first posting whose ordinal is at least target, using the skip
+// entries to jump over whole blocks.
+func (it *postingIterator) advance(target uint32) bool {
+	if it.list == nil {
+		return false
+	}
+	if it.index > 0 && it.doc >= target {
+		return true
+	}
+	skips := it.list.skips
+	block := sort.Search(len(skips), func(i int) bool { return skips[i].doc > target }) - 1
+	if block >= 0 && block*skipInterval >= it.index {
+		it.offset = int(skips[block].offset)
+		it.index = block * skipInterval
+	}
+	for it.next() {
+		if it.doc >= target {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *postingIterator) uvarint() uint64 {
+	v, n := binary.Uvarint(it.list.data[it.offset:])
+	it.offset += n
+	return v
+}
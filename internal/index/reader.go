@@ -0,0 +1,192 @@
+package index
+
+import (
+	"sort"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+// Reader is an immutable point-in-time view over the index segments. All statistics
+// and postings returned by one Reader agree with each other, regardless of concurrent
+// writes or merges, so a query should use a single Reader throughout.
+type Reader struct {
+	set *segmentSet
+}
+
+// locate returns the segment holding the live version of a document and its ordinal.
+func (r *Reader) locate(docID string) (*segmentRef, uint32, bool) {
+	refs := r.set.refs
+	for i := len(refs) - 1; i >= 0; i-- {
+		if ord, ok := refs[i].live(docID); ok {
+			return refs[i], ord, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Document retrieves a stored document by ID.
+func (r *Reader) Document(id string) (*docs.Document, bool) {
+	ref, ord, ok := r.locate(id)
+	if !ok {
+		return nil, false
+	}
+	return ref.seg.documents[ord], true
+}
+
+// FieldNames returns the indexed field names in configuration order.
+func (r *Reader) FieldNames() []string {
+	return append([]string(nil), r.set.fieldNames...)
+}
+
+// Postings returns the body postings list for a term.
+func (r *Reader) Postings(term string) []Posting {
+	return r.FieldPostings(FieldBody, term)
+}
+
+// FieldPostings returns the postings list for a term within one field, skipping
+// deleted documents.
+func (r *Reader) FieldPostings(field, term string) []Posting {
+	var results []Posting
+	for _, ref := range r.set.refs {
+		f, ok := ref.seg.fields[field]
+		if !ok {
+			continue
+		}
+		it := f.postings[term].iterator()
+		for it.next() {
+			docID := ref.seg.docIDs[it.doc]
+			if !ref.isDeleted(docID) {
+				results = append(results, Posting{DocID: docID, TF: float64(len(it.positions)), Positions: it.positions})
+			}
+		}
+	}
+	return results
+}
+
+// Posting returns the body posting of a term for a single document.
+func (r *Reader) Posting(term, docID string) (Posting, bool) {
+	return r.FieldPosting(FieldBody, term, docID)
+}
+
+// FieldPosting returns the posting of a term for a single document within one field.
+func (r *Reader) FieldPosting(field, term, docID string) (Posting, bool) {
+	ref, ord, ok := r.locate(docID)
+	if !ok {
+		return Posting{}, false
+	}
+	return ref.seg.posting(field, term, ord)
+}
+
+// DocumentFrequency returns the number of documents containing the term in any field.
+func (r *Reader) DocumentFrequency(term string) int {
+	df := 0
+	for _, ref := range r.set.refs {
+		df += ref.seg.docFreq[term] - ref.dfDelta[term]
+	}
+	return df
+}
+
+// FieldDocumentFrequency returns the number of documents containing the term in one field.
+func (r *Reader) FieldDocumentFrequency(field, term string) int {
+	df := 0
+	for _, ref := range r.set.refs {
+		f, ok := ref.seg.fields[field]
+		if !ok {
+			continue
+		}
+		list, ok := f.postings[term]
+		if !ok {
+			continue
+		}
+		df += list.count
+		for docID := range ref.deleted {
+			if ord, ok := ref.seg.ordinal(docID); ok {
+				if _, ok := ref.seg.posting(field, term, ord); ok {
+					df--
+				}
+			}
+		}
+	}
+	return df
+}
+
+// DocumentCount returns the number of indexed documents.
+func (r *Reader) DocumentCount() int {
+	return r.set.docCount
+}
+
+// AverageDocumentLength returns the average body token length across indexed documents.
+func (r *Reader) AverageDocumentLength() float64 {
+	return r.AverageFieldLength(FieldBody)
+}
+
+// AverageFieldLength returns the average token length of a field across indexed documents.
+func (r *Reader) AverageFieldLength(field string) float64 {
+	if r.set.docCount == 0 {
+		return 0
+	}
+	return float64(r.set.fieldTokens[field]) / float64(r.set.docCount)
+}
+
+// FieldLength returns the token length of a field for one document.
+func (r *Reader) FieldLength(field, docID string) int {
+	ref, ord, ok := r.locate(docID)
+	if !ok {
+		return 0
+	}
+	f, ok := ref.seg.fields[field]
+	if !ok {
+		return 0
+	}
+	return int(f.lengths[ord])
+}
+
+// TermFrequency is a distinct indexed term and the number of live documents containing it.
+type TermFrequency struct {
+	Term    string
+	DocFreq int
+}
+
+// Terms returns every term of the live documents, in any field, sorted with its
+// document frequency. The dictionary is built on first use and shared by every reader
+// of the same segments, so callers must not modify it.
+func (r *Reader) Terms() []TermFrequency {
+	set := r.set
+	set.termsOnce.Do(func() {
+		df := make(map[string]int)
+		for _, ref := range set.refs {
+			for term, n := range ref.seg.docFreq {
+				df[term] += n - ref.dfDelta[term]
+			}
+		}
+		set.terms = make([]TermFrequency, 0, len(df))
+		for term, n := range df {
+			if n > 0 {
+				set.terms = append(set.terms, TermFrequency{Term: term, DocFreq: n})
+			}
+		}
+		sort.Slice(set.terms, func(i, j int) bool { return set.terms[i].Term < set.terms[j].Term })
+	})
+	return set.terms
+}
+
+// Version identifies the segments the reader sees. It increases every time the index
+// publishes new segments or deletions, so callers can tell when data derived from an
+// earlier reader is stale.
+func (r *Reader) Version() uint64 {
+	return r.set.version
+}
+
+// Documents returns all documents sorted by ID for deterministic ordering.
+func (r *Reader) Documents() []*docs.Document {
+	result := make([]*docs.Document, 0, r.set.docCount)
+	for _, ref := range r.set.refs {
+		for ord, doc := range ref.seg.documents {
+			if !ref.isDeleted(ref.seg.docIDs[ord]) {
+				result = append(result, doc)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
@@ -0,0 +1,344 @@
+package index
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+// segment is an immutable, compacted set of documents produced by freezing the write
+// buffer or by merging other segments. Documents are addressed by dense ordinals
+// assigned in ID order, which keeps postings small. Segments are never modified once
+// published; deletions are tracked beside them in segmentRef.
+type segment struct {
+	id        uint64
+	docIDs    []string
+	documents []*docs.Document
+	ordinals  map[string]uint32
+	docTerms  [][]string
+	fields    map[string]*segmentField
+	docFreq   map[string]int
+	// postingBytes is the encoded size of all postings lists; rawBytes estimates what
+	// the same postings would cost as maps of pointers.
+	postingBytes int
+	rawBytes     int
+}
+
+// segmentField stores one field's compressed postings and per-document lengths.
+type segmentField struct {
+	postings    map[string]*postingList
+	lengths     []uint32
+	totalTokens int
+}
+
+func (s *segment) ordinal(docID string) (uint32, bool) {
+	ord, ok := s.ordinals[docID]
+	return ord, ok
+}
+
+// posting seeks the posting of a term for one document ordinal.
+func (s *segment) posting(field, term string, ord uint32) (Posting, bool) {
+	f, ok := s.fields[field]
+	if !ok {
+		return Posting{}, false
+	}
+	it := f.postings[term].iterator()
+	if !it.advance(ord) || it.doc != ord {
+		return Posting{}, false
+	}
+	return Posting{DocID: s.docIDs[ord], TF: float64(len(it.positions)), Positions: it.positions}, true
+}
+
+// segmentBuilder collects decoded documents and postings before encoding a segment.
+type segmentBuilder struct {
+	documents map[string]*docs.Document
+	docTerms  map[string][]string
+	fields    map[string]*fieldBuilder
+}
+
+type fieldBuilder struct {
+	postings map[string][]rawPosting
+	lengths  map[string]int
+}
+
+func newSegmentBuilder(fieldNames []string) *segmentBuilder {
+	b := &segmentBuilder{
+		documents: make(map[string]*docs.Document),
+		docTerms:  make(map[string][]string),
+		fields:    make(map[string]*fieldBuilder, len(fieldNames)),
+	}
+	for _, name := range fieldNames {
+		b.fields[name] = &fieldBuilder{postings: make(map[string][]rawPosting), lengths: make(map[string]int)}
+	}
+	return b
+}
+
+func (b *segmentBuilder) build(id uint64) *segment {
+	seg := &segment{
+		id:       id,
+		docIDs:   make([]string, 0, len(b.documents)),
+		ordinals: make(map[string]uint32, len(b.documents)),
+		fields:   make(map[string]*segmentField, len(b.fields)),
+		docFreq:  make(map[string]int),
+	}
+	for docID := range b.documents {
+		seg.docIDs = append(seg.docIDs, docID)
+	}
+	sort.Strings(seg.docIDs)
+	seg.documents = make([]*docs.Document, len(seg.docIDs))
+	seg.docTerms = make([][]string, len(seg.docIDs))
+	for ord, docID := range seg.docIDs {
+		seg.ordinals[docID] = uint32(ord)
+		seg.documents[ord] = b.documents[docID]
+		seg.docTerms[ord] = b.docTerms[docID]
+		for _, term := range b.docTerms[docID] {
+			seg.docFreq[term]++
+		}
+	}
+
+	for name, fb := range b.fields {
+		sf := &segmentField{
+			postings: make(map[string]*postingList, len(fb.postings)),
+			lengths:  make([]uint32, len(seg.docIDs)),
+		}
+		for docID, length := range fb.lengths {
+			sf.lengths[seg.ordinals[docID]] = uint32(length)
+			sf.totalTokens += length
+		}
+		for term, raw := range fb.postings {
+			encoded := make([]encodedPosting, len(raw))
+			for i, p := range raw {
+				encoded[i] = encodedPosting{doc: seg.ordinals[p.docID], positions: p.positions}
+				seg.rawBytes += mapPostingOverhead + len(p.docID) + 8*len(p.positions)
+			}
+			list := encodePostings(encoded)
+			seg.postingBytes += list.sizeBytes()
+			sf.postings[term] = list
+		}
+		seg.fields[name] = sf
+	}
+	return seg
+}
+
+// writeBuffer is the mutable in-memory segment receiving new documents. It is only
+// touched by writers holding the index lock; readers see a frozen copy of it.
+type writeBuffer struct {
+	documents map[string]*docs.Document
+	fields    map[string]*fieldIndex
+}
+
+func newWriteBuffer(fields []Field) *writeBuffer {
+	b := &writeBuffer{
+		documents: make(map[string]*docs.Document),
+		fields:    make(map[string]*fieldIndex, len(fields)),
+	}
+	for _, field := range fields {
+		b.fields[field.Name] = newFieldIndex()
+	}
+	return b
+}
+
+func (b *writeBuffer) add(doc *docs.Document, fields []Field, fieldTokens [][]string, fieldPositions [][]int) {
+	for i, field := range fields {
+		b.fields[field.Name].add(doc.ID, fieldTokens[i], fieldPositions[i])
+	}
+	b.documents[doc.ID] = doc
+}
+
+func (b *writeBuffer) remove(id string) bool {
+	if _, ok := b.documents[id]; !ok {
+		return false
+	}
+	for _, f := range b.fields {
+		f.remove(id)
+	}
+	delete(b.documents, id)
+	return true
+}
+
+// freeze converts the buffer into an immutable, compressed segment.
+func (b *writeBuffer) freeze(id uint64, fieldNames []string) *segment {
+	builder := newSegmentBuilder(fieldNames)
+	builder.documents = b.documents
+	distinct := make(map[string]map[string]struct{}, len(b.documents))
+	for name, f := range b.fields {
+		fb := builder.fields[name]
+		fb.lengths = f.docLengths
+		for term, entry := range f.postings {
+			raw := make([]rawPosting, 0, len(entry))
+			for docID, posting := range entry {
+				raw = append(raw, rawPosting{docID: docID, positions: posting.Positions})
+			}
+			fb.postings[term] = raw
+		}
+		for docID, terms := range f.docTerms {
+			set, ok := distinct[docID]
+			if !ok {
+				set = make(map[string]struct{}, len(terms))
+				distinct[docID] = set
+			}
+			for _, term := range terms {
+				set[term] = struct{}{}
+			}
+		}
+	}
+	for docID, set := range distinct {
+		terms := make([]string, 0, len(set))
+		for term := range set {
+			terms = append(terms, term)
+		}
+		builder.docTerms[docID] = terms
+	}
+	return builder.build(id)
+}
+
+// mergeSegments combines the live documents of refs into a single new segment.
+func mergeSegments(id uint64, fieldNames []string, refs []*segmentRef) *segment {
+	builder := newSegmentBuilder(fieldNames)
+	for _, ref := range refs {
+		seg := ref.seg
+		for ord, docID := range seg.docIDs {
+			if ref.isDeleted(docID) {
+				continue
+			}
+			builder.documents[docID] = seg.documents[ord]
+			builder.docTerms[docID] = seg.docTerms[ord]
+		}
+		for name, src := range seg.fields {
+			fb, ok := builder.fields[name]
+			if !ok {
+				continue
+			}
+			for ord, length := range src.lengths {
+				if docID := seg.docIDs[ord]; !ref.isDeleted(docID) {
+					fb.lengths[docID] = int(length)
+				}
+			}
+			for term, list := range src.postings {
+				it := list.iterator()
+				for it.next() {
+					docID := seg.docIDs[it.doc]
+					if !ref.isDeleted(docID) {
+						fb.postings[term] = append(fb.postings[term], rawPosting{docID: docID, positions: it.positions})
+					}
+				}
+			}
+		}
+	}
+	return builder.build(id)
+}
+
+// segmentRef pairs a segment with the documents deleted from it. A published ref is
+// immutable; deleting another document creates a new ref.
+type segmentRef struct {
+	seg     *segment
+	deleted map[string]struct{}
+	// dfDelta counts deleted documents per term so document frequencies stay exact.
+	dfDelta map[string]int
+}
+
+func newSegmentRef(seg *segment) *segmentRef {
+	return &segmentRef{seg: seg}
+}
+
+// newSegmentRefWithDeleted builds a ref with many deletions at once, as when a
+// segment is restored from a snapshot.
+func newSegmentRefWithDeleted(seg *segment, deleted []string) *segmentRef {
+	ref := &segmentRef{seg: seg}
+	if len(deleted) == 0 {
+		return ref
+	}
+	ref.deleted = make(map[string]struct{}, len(deleted))
+	ref.dfDelta = make(map[string]int)
+	for _, id := range deleted {
+		ord, ok := seg.ordinal(id)
+		if !ok {
+			continue
+		}
+		ref.deleted[id] = struct{}{}
+		for _, term := range seg.docTerms[ord] {
+			ref.dfDelta[term]++
+		}
+	}
+	return ref
+}
+
+func (r *segmentRef) isDeleted(docID string) bool {
+	_, deleted := r.deleted[docID]
+	return deleted
+}
+
+// live returns the ordinal of a document that is stored in the segment and not deleted.
+func (r *segmentRef) live(docID string) (uint32, bool) {
+	ord, ok := r.seg.ordinal(docID)
+	if !ok || r.isDeleted(docID) {
+		return 0, false
+	}
+	return ord, true
+}
+
+func (r *segmentRef) liveCount() int {
+	return len(r.seg.docIDs) - len(r.deleted)
+}
+
+func (r *segmentRef) withDeleted(docIDs ...string) *segmentRef {
+	next := &segmentRef{
+		seg:     r.seg,
+		deleted: make(map[string]struct{}, len(r.deleted)+len(docIDs)),
+		dfDelta: make(map[string]int, len(r.dfDelta)),
+	}
+	for id := range r.deleted {
+		next.deleted[id] = struct{}{}
+	}
+	for term, n := range r.dfDelta {
+		next.dfDelta[term] = n
+	}
+	for _, docID := range docIDs {
+		if _, ok := next.deleted[docID]; ok {
+			continue
+		}
+		next.deleted[docID] = struct{}{}
+		if ord, ok := r.seg.ordinal(docID); ok {
+			for _, term := range r.seg.docTerms[ord] {
+				next.dfDelta[term]++
+			}
+		}
+	}
+	return next
+}
+
+// segmentSet is the immutable list of segments visible to readers at one point in time,
+// along with collection statistics over their live documents.
+type segmentSet struct {
+	refs         []*segmentRef
+	fieldNames   []string
+	docCount     int
+	fieldTokens  map[string]int
+	postingBytes int
+	rawBytes     int
+	version      uint64
+
+	// terms is the sorted term dictionary, built lazily by Reader.Terms.
+	termsOnce sync.Once
+	terms     []TermFrequency
+}
+
+func newSegmentSet(fieldNames []string, refs []*segmentRef) *segmentSet {
+	set := &segmentSet{refs: refs, fieldNames: fieldNames, fieldTokens: make(map[string]int, len(fieldNames))}
+	for _, ref := range refs {
+		set.docCount += ref.liveCount()
+		set.postingBytes += ref.seg.postingBytes
+		set.rawBytes += ref.seg.rawBytes
+		for name, f := range ref.seg.fields {
+			tokens := f.totalTokens
+			for docID := range ref.deleted {
+				if ord, ok := ref.seg.ordinal(docID); ok {
+					tokens -= int(f.lengths[ord])
+				}
+			}
+			set.fieldTokens[name] += tokens
+		}
+	}
+	return set
+}
@@ -0,0 +1,281 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+// ShardedIndex partitions documents across independent InvertedIndex shards by a hash
+// of their ID. Writes to different shards do not contend, and a query can search the
+// shards in parallel, combining their statistics so scores stay comparable.
+type ShardedIndex struct {
+	shards []*InvertedIndex
+}
+
+// NewShardedIndex constructs n empty shards over the provided fields, or the default
+// fields when none are given. n is at least one.
+func NewShardedIndex(n int, fields ...Field) *ShardedIndex {
+	if len(fields) == 0 {
+		fields = DefaultFields()
+	}
+	s := &ShardedIndex{shards: make([]*InvertedIndex, max(n, 1))}
+	for i := range s.shards {
+		s.shards[i] = NewFieldedIndex(fields...)
+	}
+	return s
+}
+
+// ShardOf returns the shard that owns docID among n shards. Every node of a cluster
+// uses it, so a document is always routed to the same shard.
+func ShardOf(docID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(docID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Shards returns the shards in order. The slice must not be modified.
+func (s *ShardedIndex) Shards() []*InvertedIndex {
+	return s.shards
+}
+
+// Shard returns the shard that owns docID.
+func (s *ShardedIndex) Shard(docID string) *InvertedIndex {
+	return s.shards[ShardOf(docID, len(s.shards))]
+}
+
+// SetAnalyzer sets the analyzer of every shard. Call it before adding documents.
+func (s *ShardedIndex) SetAnalyzer(analyzer analysis.Analyzer) {
+	for _, shard := range s.shards {
+		shard.Analyzer = analyzer
+	}
+}
+
+// Analyzer returns the analyzer shared by the shards.
+func (s *ShardedIndex) Analyzer() analysis.Analyzer {
+	return s.shards[0].Analyzer
+}
+
+// AddDocument indexes the document in the shard that owns its ID.
+func (s *ShardedIndex) AddDocument(doc *docs.Document) {
+	s.Shard(doc.ID).AddDocument(doc)
+}
+
+// RemoveDocument deletes a document from its shard, reporting whether it was indexed.
+func (s *ShardedIndex) RemoveDocument(id string) bool {
+	return s.Shard(id).RemoveDocument(id)
+}
+
+// RemoveByParent deletes every document derived from the given parent. Children are
+// routed by their own IDs, so every shard is searched.
+func (s *ShardedIndex) RemoveByParent(parentID string) int {
+	removed := 0
+	for _, shard := range s.shards {
+		removed += shard.RemoveByParent(parentID)
+	}
+	return removed
+}
+
+// Refresh freezes the buffered documents of every shard.
+func (s *ShardedIndex) Refresh() {
+	for _, shard := range s.shards {
+		shard.Refresh()
+	}
+}
+
+// RunMerger runs the background merger of every shard until ctx is cancelled.
+func (s *ShardedIndex) RunMerger(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	for _, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shard.RunMerger(ctx, interval)
+		}()
+	}
+	wg.Wait()
+}
+
+// Readers returns a point-in-time reader for every shard, in shard order.
+func (s *ShardedIndex) Readers() []*Reader {
+	readers := make([]*Reader, len(s.shards))
+	for i, shard := range s.shards {
+		readers[i] = shard.Reader()
+	}
+	return readers
+}
+
+// Document retrieves a stored document by ID from its shard.
+func (s *ShardedIndex) Document(id string) (*docs.Document, bool) {
+	return s.Shard(id).Document(id)
+}
+
+// DocumentCount returns the number of documents across all shards.
+func (s *ShardedIndex) DocumentCount() int {
+	count := 0
+	for _, shard := range s.shards {
+		count += shard.DocumentCount()
+	}
+	return count
+}
+
+// Stats are the collection statistics BM25 scoring needs for a set of terms. Stats of
+// several shards are added together with Merge, so each shard can score its documents
+// against the whole collection.
+type Stats struct {
+	DocCount     int                       `json:"doc_count"`
+	FieldTokens  map[string]int            `json:"field_tokens"`
+	DocFreq      map[string]int            `json:"doc_freq"`
+	FieldDocFreq map[string]map[string]int `json:"field_doc_freq"`
+}
+
+// Stats returns the reader's collection statistics for terms in every field.
+func (r *Reader) Stats(terms []string) Stats {
+	stats := Stats{
+		DocCount:     r.set.docCount,
+		FieldTokens:  make(map[string]int, len(r.set.fieldNames)),
+		DocFreq:      make(map[string]int, len(terms)),
+		FieldDocFreq: make(map[string]map[string]int, len(r.set.fieldNames)),
+	}
+	for _, field := range r.set.fieldNames {
+		stats.FieldTokens[field] = r.set.fieldTokens[field]
+		stats.FieldDocFreq[field] = make(map[string]int, len(terms))
+	}
+	for _, term := range terms {
+		if _, ok := stats.DocFreq[term]; ok {
+			continue
+		}
+		stats.DocFreq[term] = r.DocumentFrequency(term)
+		for _, field := range r.set.fieldNames {
+			stats.FieldDocFreq[field][term] = r.FieldDocumentFrequency(field, term)
+		}
+	}
+	return stats
+}
+
+// Merge adds other to s.
+func (s *Stats) Merge(other Stats) {
+	s.DocCount += other.DocCount
+	if s.FieldTokens == nil {
+		s.FieldTokens = make(map[string]int, len(other.FieldTokens))
+	}
+	for field, n := range other.FieldTokens {
+		s.FieldTokens[field] += n
+	}
+	if s.DocFreq == nil {
+		s.DocFreq = make(map[string]int, len(other.DocFreq))
+	}
+	for term, n := range other.DocFreq {
+		s.DocFreq[term] += n
+	}
+	if s.FieldDocFreq == nil {
+		s.FieldDocFreq = make(map[string]map[string]int, len(other.FieldDocFreq))
+	}
+	for field, terms := range other.FieldDocFreq {
+		if s.FieldDocFreq[field] == nil {
+			s.FieldDocFreq[field] = make(map[string]int, len(terms))
+		}
+		for term, n := range terms {
+			s.FieldDocFreq[field][term] += n
+		}
+	}
+}
+
+// DocumentCount returns the number of documents in the collection.
+func (s Stats) DocumentCount() int {
+	return s.DocCount
+}
+
+// DocumentFrequency returns the number of documents containing the term in any field.
+func (s Stats) DocumentFrequency(term string) int {
+	return s.DocFreq[term]
+}
+
+// FieldDocumentFrequency returns the number of documents containing the term in one field.
+func (s Stats) FieldDocumentFrequency(field, term string) int {
+	return s.FieldDocFreq[field][term]
+}
+
+// AverageFieldLength returns the average token length of a field across the collection.
+func (s Stats) AverageFieldLength(field string) float64 {
+	if s.DocCount == 0 {
+		return 0
+	}
+	return float64(s.FieldTokens[field]) / float64(s.DocCount)
+}
+
+// MergeTerms combines the term dictionaries of several readers into one sorted
+// dictionary, adding up the document frequencies of shared terms.
+func MergeTerms(readers []*Reader) []TermFrequency {
+	if len(readers) == 1 {
+		return readers[0].Terms()
+	}
+	var merged []TermFrequency
+	for _, r := range readers {
+		merged = append(merged, r.Terms()...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Term < merged[j].Term })
+	out := merged[:0]
+	for _, tf := range merged {
+		if n := len(out); n > 0 && out[n-1].Term == tf.Term {
+			out[n-1].DocFreq += tf.DocFreq
+			continue
+		}
+		out = append(out, tf)
+	}
+	return out
+}
+
+// shardSectionName names the snapshot section of shard i of n. A single shard uses the
+// plain name, so its snapshots are interchangeable with those of an unsharded index.
+func shardSectionName(name string, i, n int) string {
+	if n == 1 {
+		return name
+	}
+	return fmt.Sprintf("%s/%d-of-%d", name, i, n)
+}
+
+// AddShardedIndex records every shard of s under name.
+func (w *SnapshotWriter) AddShardedIndex(name string, s *ShardedIndex) {
+	for i, shard := range s.shards {
+		w.AddIndex(shardSectionName(name, i, len(s.shards)), shard)
+	}
+}
+
+// RestoreShardedIndex installs the named shards into s, which must be empty. It
+// reports false without error when the snapshot has no such index, and fails when the
+// snapshot was written with a different number of shards; callers then re-index
+// Documents, which routes them to the current shards.
+func (l *LoadedSnapshot) RestoreShardedIndex(name string, s *ShardedIndex) (bool, error) {
+	n := len(s.shards)
+	found := 0
+	for i := range s.shards {
+		if _, ok := l.indexes[shardSectionName(name, i, n)]; ok {
+			found++
+		}
+	}
+	if found < n {
+		for section := range l.indexes {
+			if section == name || strings.HasPrefix(section, name+"/") {
+				return false, fmt.Errorf("snapshot index %q is not split into %d shards", name, n)
+			}
+		}
+		return false, nil
+	}
+	for i, shard := range s.shards {
+		if _, err := l.RestoreIndex(shardSectionName(name, i, n), shard); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
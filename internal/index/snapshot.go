@@ -1,77 +1,415 @@
 package index
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
 )
 
-// Snapshot contains a serializable view of the inverted index.
+// SnapshotVersion is the binary snapshot format version written by this package.
+// Version 1 held only documents; version 2 adds prebuilt index segments and blobs;
+// version 3 records the analyzer that produced each index's terms.
+const SnapshotVersion = 3
+
+// snapshotMagic opens every binary snapshot.
+const snapshotMagic = "DSESNAP\x00"
+
+// snapshotHeaderSize is the magic, version, flags, section count, and header checksum.
+const snapshotHeaderSize = len(snapshotMagic) + 2 + 2 + 4 + 4
+
+// Snapshot section tags.
+const (
+	sectionDocuments uint32 = 1
+	sectionIndex     uint32 = 2
+	sectionBlob      uint32 = 3
+)
+
+// Names of the sections written by the indexer.
+const (
+	SnapshotPages    = "pages"
+	SnapshotImages   = "images"
+	SnapshotSemantic = "semantic"
+	SnapshotOffsets  = "offsets"
+)
+
+var snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrSnapshotCorrupt reports a snapshot whose header or section checksums do not match.
+var ErrSnapshotCorrupt = errors.New("snapshot corrupt")
+
+// Snapshot is the legacy JSON snapshot layout. It is still read so older snapshots can
+// be loaded and rewritten in the binary format.
 type Snapshot struct {
 	Documents []*SnapshotDocument `json:"documents"`
 }
 
-// SnapshotDocument is a lightweight representation persisted to disk.
+// SnapshotDocument is a document in the legacy JSON snapshot layout.
 type SnapshotDocument struct {
-	ID      string   `json:"id"`
-	URL     string   `json:"url"`
-	Title   string   `json:"title"`
-	Tokens  []string `json:"tokens"`
-	Content string   `json:"content"`
+	ID       string            `json:"id"`
+	URL      string            `json:"url"`
+	Title    string            `json:"title"`
+	Tokens   []string          `json:"tokens"`
+	Content  string            `json:"content"`
+	Type     string            `json:"type,omitempty"`
+	ParentID string            `json:"parent_id,omitempty"`
+	Image    *docs.ImageInfo   `json:"image,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// LoadedSnapshot is the result of LoadSnapshot.
+type LoadedSnapshot struct {
+	// Path is the file that was read, which is the previous snapshot after a fallback.
+	Path string
+	// Version is the binary format version, or zero for a legacy JSON snapshot.
+	Version int
+	// Documents holds every live document, whether stored on its own or in an index.
+	Documents []*docs.Document
+	// PrimaryErr explains why the primary snapshot was skipped when Path is the fallback.
+	PrimaryErr error
+
+	indexes map[string]*indexImage
+	blobs   map[string][]byte
+}
+
+// Blob returns a named opaque section, such as a serialized semantic index.
+func (l *LoadedSnapshot) Blob(name string) ([]byte, bool) {
+	data, ok := l.blobs[name]
+	return data, ok
+}
+
+// StreamPosition is how far into a document topic a snapshot reaches: the next offset to
+// read from each partition, where a negative offset stands for the start of a partition
+// nothing has been read from. Replaying the topic from it applies exactly the changes
+// the snapshot does not contain.
+type StreamPosition struct {
+	Topic   string
+	Offsets map[int]int64
+}
+
+// Position returns the stream position recorded in the snapshot, reporting false for
+// snapshots written without one.
+func (l *LoadedSnapshot) Position() (StreamPosition, bool, error) {
+	data, ok := l.blobs[SnapshotOffsets]
+	if !ok {
+		return StreamPosition{}, false, nil
+	}
+	dec := sectionDecoder{data: data}
+	pos := StreamPosition{Topic: dec.string()}
+	n := dec.count()
+	pos.Offsets = make(map[int]int64, n)
+	for i := 0; i < n; i++ {
+		partition := dec.varint()
+		pos.Offsets[int(partition)] = dec.varint()
+	}
+	if dec.err != nil {
+		return StreamPosition{}, false, fmt.Errorf("decode stream position: %w", dec.err)
+	}
+	return pos, true, nil
 }
 
-// WriteSnapshot exports the index and documents to the provided path.
+// SnapshotWriter assembles a snapshot from indexes and opaque blobs.
+type SnapshotWriter struct {
+	sections []snapshotSection
+}
+
+// AddIndex records the current segments of idx under name, freezing buffered documents
+// first so that the snapshot holds every completed write. Posting lists are copied as
+// encoded, so the cost is proportional to the index size rather than to re-indexing.
+func (w *SnapshotWriter) AddIndex(name string, idx *InvertedIndex) {
+	var enc sectionEncoder
+	encodeIndex(&enc, name, idx.Analyzer.Name(), idx.frozenReader())
+	w.sections = append(w.sections, snapshotSection{tag: sectionIndex, data: enc.buf})
+}
+
+// AddBlob records an opaque named section owned by another package.
+func (w *SnapshotWriter) AddBlob(name string, data []byte) {
+	var enc sectionEncoder
+	enc.string(name)
+	enc.buf = append(enc.buf, data...)
+	w.sections = append(w.sections, snapshotSection{tag: sectionBlob, data: enc.buf})
+}
+
+// AddPosition records the stream position the snapshot's indexes contain.
+func (w *SnapshotWriter) AddPosition(pos StreamPosition) {
+	partitions := make([]int, 0, len(pos.Offsets))
+	for partition := range pos.Offsets {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	var enc sectionEncoder
+	enc.string(pos.Topic)
+	enc.uvarint(uint64(len(partitions)))
+	for _, partition := range partitions {
+		enc.varint(int64(partition))
+		enc.varint(pos.Offsets[partition])
+	}
+	w.AddBlob(SnapshotOffsets, enc.buf)
+}
+
+// Write stores the snapshot at path. The snapshot is written to a temporary file and
+// synced before being renamed into place, and the snapshot it replaces is kept as a
+// fallback.
+func (w *SnapshotWriter) Write(path string) error {
+	return writeSnapshotFile(path, w.sections)
+}
+
+// PreviousSnapshotPath returns where the snapshot replaced by the latest write is kept.
+func PreviousSnapshotPath(path string) string {
+	return path + ".prev"
+}
+
+// LegacySnapshotPath returns the name older releases gave the snapshot at path, from
+// when snapshots were JSON files.
+func LegacySnapshotPath(path string) string {
+	return path + ".json"
+}
+
+// WriteSnapshot exports a single index to the provided path.
 func WriteSnapshot(idx *InvertedIndex, path string) error {
-	docs := idx.Documents()
-	snapshot := Snapshot{Documents: make([]*SnapshotDocument, 0, len(docs))}
-	for _, doc := range docs {
-		snapshot.Documents = append(snapshot.Documents, &SnapshotDocument{
-			ID:      doc.ID,
-			URL:     doc.URL,
-			Title:   doc.Title,
-			Tokens:  doc.Tokens,
-			Content: doc.Content,
-		})
+	var w SnapshotWriter
+	w.AddIndex(SnapshotPages, idx)
+	return w.Write(path)
+}
+
+// LoadSnapshot reads the snapshot at path, falling back to the previous snapshot when
+// the primary is missing, truncated, or fails its checksums. When neither exists, the
+// snapshot is read from its LegacySnapshotPath, so upgrades keep their data until the
+// next write. Legacy JSON snapshots are read transparently.
+func LoadSnapshot(path string) (*LoadedSnapshot, error) {
+	loaded, err := ReadSnapshot(path)
+	if err == nil {
+		return loaded, nil
+	}
+	previous := PreviousSnapshotPath(path)
+	fallback, prevErr := ReadSnapshot(previous)
+	if prevErr == nil {
+		fallback.PrimaryErr = err
+		return fallback, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) && errors.Is(prevErr, fs.ErrNotExist) {
+		legacy, legacyErr := ReadSnapshot(LegacySnapshotPath(path))
+		if legacyErr == nil {
+			return legacy, nil
+		}
+		if !errors.Is(legacyErr, fs.ErrNotExist) {
+			return nil, legacyErr
+		}
 	}
+	return nil, err
+}
 
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+// ReadSnapshot reads a single snapshot file without falling back.
+func ReadSnapshot(path string) (*LoadedSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		documents, err := decodeLegacySnapshot(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &LoadedSnapshot{Path: path, Documents: documents}, nil
 	}
 
-	file, err := os.Create(path)
+	version, sections, err := parseSnapshot(data)
 	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	loaded := &LoadedSnapshot{
+		Path:    path,
+		Version: version,
+		indexes: make(map[string]*indexImage),
+		blobs:   make(map[string][]byte),
+	}
+	for _, section := range sections {
+		switch section.tag {
+		case sectionDocuments:
+			documents, err := decodeDocuments(section.data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: documents: %w", path, err)
+			}
+			loaded.Documents = append(loaded.Documents, documents...)
+		case sectionIndex:
+			name, image, err := decodeIndex(section.data, version)
+			if err != nil {
+				return nil, fmt.Errorf("%s: index: %w", path, err)
+			}
+			loaded.indexes[name] = image
+			for _, ref := range image.refs {
+				for ord, doc := range ref.seg.documents {
+					if !ref.isDeleted(ref.seg.docIDs[ord]) {
+						loaded.Documents = append(loaded.Documents, doc)
+					}
+				}
+			}
+		case sectionBlob:
+			dec := &sectionDecoder{data: section.data}
+			name := dec.string()
+			if dec.err != nil {
+				return nil, fmt.Errorf("%s: blob: %w", path, dec.err)
+			}
+			loaded.blobs[name] = dec.data
+		}
+	}
+	return loaded, nil
+}
+
+type snapshotSection struct {
+	tag  uint32
+	data []byte
+}
+
+// writeSnapshotFile writes the header and sections to a synced temporary file, keeps
+// the current snapshot as the previous one, and renames the new file into place.
+func writeSnapshotFile(path string, sections []snapshotSection) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	header := make([]byte, 0, snapshotHeaderSize)
+	header = append(header, snapshotMagic...)
+	header = binary.LittleEndian.AppendUint16(header, SnapshotVersion)
+	header = binary.LittleEndian.AppendUint16(header, 0)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(sections)))
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, snapshotTable))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, section := range sections {
+		var frame [16]byte
+		binary.LittleEndian.PutUint32(frame[0:], section.tag)
+		binary.LittleEndian.PutUint64(frame[4:], uint64(len(section.data)))
+		binary.LittleEndian.PutUint32(frame[12:], crc32.Checksum(section.data, snapshotTable))
+		if _, err := w.Write(frame[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(section.data); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(&snapshot)
+	if _, statErr := os.Stat(path); statErr == nil {
+		if err := os.Rename(path, PreviousSnapshotPath(path)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
 }
 
-// LoadSnapshot reads documents from disk allowing callers to hydrate indexes.
-func LoadSnapshot(path string) ([]*docs.Document, error) {
-	file, err := os.Open(path)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
+	defer d.Close()
+	return d.Sync()
+}
+
+// parseSnapshot verifies the header and every section checksum.
+func parseSnapshot(data []byte) (int, []snapshotSection, error) {
+	if len(data) < snapshotHeaderSize || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, nil, fmt.Errorf("%w: bad header", ErrSnapshotCorrupt)
+	}
+	header := data[:snapshotHeaderSize-4]
+	if crc32.Checksum(header, snapshotTable) != binary.LittleEndian.Uint32(data[snapshotHeaderSize-4:]) {
+		return 0, nil, fmt.Errorf("%w: header checksum mismatch", ErrSnapshotCorrupt)
+	}
+	version := int(binary.LittleEndian.Uint16(data[len(snapshotMagic):]))
+	if version > SnapshotVersion {
+		return 0, nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	count := int(binary.LittleEndian.Uint32(data[len(snapshotMagic)+4:]))
+
+	sections := make([]snapshotSection, 0, count)
+	rest := data[snapshotHeaderSize:]
+	for i := 0; i < count; i++ {
+		if len(rest) < 16 {
+			return 0, nil, fmt.Errorf("%w: truncated section %d", ErrSnapshotCorrupt, i)
+		}
+		tag := binary.LittleEndian.Uint32(rest[0:])
+		length := binary.LittleEndian.Uint64(rest[4:])
+		sum := binary.LittleEndian.Uint32(rest[12:])
+		rest = rest[16:]
+		if length > uint64(len(rest)) {
+			return 0, nil, fmt.Errorf("%w: truncated section %d", ErrSnapshotCorrupt, i)
+		}
+		payload := rest[:length]
+		if crc32.Checksum(payload, snapshotTable) != sum {
+			return 0, nil, fmt.Errorf("%w: section %d checksum mismatch", ErrSnapshotCorrupt, tag)
+		}
+		sections = append(sections, snapshotSection{tag: tag, data: payload})
+		rest = rest[length:]
+	}
+	return version, sections, nil
+}
+
+func decodeDocuments(data []byte) ([]*docs.Document, error) {
+	dec := &sectionDecoder{data: data}
+	count := dec.count()
+	documents := make([]*docs.Document, 0, count)
+	for i := 0; i < count && dec.err == nil; i++ {
+		documents = append(documents, decodeDocument(dec))
+	}
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	return documents, nil
+}
 
+func decodeLegacySnapshot(data []byte) ([]*docs.Document, error) {
 	var snapshot Snapshot
-	if err := json.NewDecoder(file).Decode(&snapshot); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil && err != io.EOF {
 		return nil, err
 	}
-	docsOut := make([]*docs.Document, 0, len(snapshot.Documents))
+	documents := make([]*docs.Document, 0, len(snapshot.Documents))
 	for _, entry := range snapshot.Documents {
-		docsOut = append(docsOut, &docs.Document{
-			ID:      entry.ID,
-			URL:     entry.URL,
-			Title:   entry.Title,
-			Tokens:  entry.Tokens,
-			Content: entry.Content,
+		documents = append(documents, &docs.Document{
+			ID:       entry.ID,
+			URL:      entry.URL,
+			Title:    entry.Title,
+			Tokens:   entry.Tokens,
+			Content:  entry.Content,
+			Type:     entry.Type,
+			ParentID: entry.ParentID,
+			Image:    entry.Image,
+			Metadata: entry.Metadata,
 		})
 	}
-	return docsOut, nil
+	return documents, nil
 }
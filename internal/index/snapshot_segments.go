@@ -0,0 +1,278 @@
+package index
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+// encodeIndex writes the segments visible to r, including their deletions, so they can
+// be restored without re-analyzing or re-encoding any postings.
+func encodeIndex(enc *sectionEncoder, name, analyzer string, r *Reader) {
+	enc.string(name)
+	enc.string(analyzer)
+	enc.strings(r.set.fieldNames)
+	enc.uvarint(uint64(len(r.set.refs)))
+	for _, ref := range r.set.refs {
+		encodeSegment(enc, r.set.fieldNames, ref)
+	}
+}
+
+func encodeSegment(enc *sectionEncoder, fieldNames []string, ref *segmentRef) {
+	seg := ref.seg
+	enc.uvarint(uint64(len(seg.documents)))
+	for _, doc := range seg.documents {
+		encodeDocument(enc, doc)
+	}
+
+	terms := make([]string, 0, len(seg.docFreq))
+	for term := range seg.docFreq {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	termIDs := make(map[string]uint64, len(terms))
+	enc.uvarint(uint64(len(terms)))
+	for i, term := range terms {
+		termIDs[term] = uint64(i)
+		enc.string(term)
+		enc.uvarint(uint64(seg.docFreq[term]))
+	}
+
+	for _, docTerms := range seg.docTerms {
+		ids := make([]uint64, len(docTerms))
+		for i, term := range docTerms {
+			ids[i] = termIDs[term]
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		enc.uvarint(uint64(len(ids)))
+		var prev uint64
+		for _, id := range ids {
+			enc.uvarint(id - prev)
+			prev = id
+		}
+	}
+
+	for _, name := range fieldNames {
+		f := seg.fields[name]
+		enc.uvarint(uint64(f.totalTokens))
+		for _, length := range f.lengths {
+			enc.uvarint(uint64(length))
+		}
+		enc.uvarint(uint64(len(f.postings)))
+		for _, term := range terms {
+			list, ok := f.postings[term]
+			if !ok {
+				continue
+			}
+			enc.uvarint(termIDs[term])
+			enc.uvarint(uint64(list.count))
+			enc.uvarint(uint64(len(list.data)))
+			enc.buf = append(enc.buf, list.data...)
+			enc.uvarint(uint64(len(list.skips)))
+			for _, skip := range list.skips {
+				enc.uvarint(uint64(skip.doc))
+				enc.uvarint(uint64(skip.offset))
+			}
+		}
+	}
+	enc.uvarint(uint64(seg.postingBytes))
+	enc.uvarint(uint64(seg.rawBytes))
+
+	deleted := make([]string, 0, len(ref.deleted))
+	for id := range ref.deleted {
+		deleted = append(deleted, id)
+	}
+	sort.Strings(deleted)
+	enc.strings(deleted)
+}
+
+// indexImage is a decoded index section waiting to be installed into an index.
+type indexImage struct {
+	// analyzer is empty for version 2 sections, whose terms came from the ASCII-only
+	// tokenizer that preceded analyzers.
+	analyzer   string
+	fieldNames []string
+	refs       []*segmentRef
+}
+
+func decodeIndex(data []byte, version int) (string, *indexImage, error) {
+	dec := &sectionDecoder{data: data}
+	name := dec.string()
+	image := &indexImage{}
+	if version >= 3 {
+		image.analyzer = dec.string()
+	}
+	image.fieldNames = dec.strings()
+	count := dec.count()
+	for i := 0; i < count && dec.err == nil; i++ {
+		image.refs = append(image.refs, decodeSegment(dec, image.fieldNames))
+	}
+	if dec.err != nil {
+		return "", nil, dec.err
+	}
+	return name, image, nil
+}
+
+func decodeSegment(dec *sectionDecoder, fieldNames []string) *segmentRef {
+	n := dec.count()
+	seg := &segment{
+		docIDs:    make([]string, n),
+		documents: make([]*docs.Document, n),
+		ordinals:  make(map[string]uint32, n),
+		docTerms:  make([][]string, n),
+		fields:    make(map[string]*segmentField, len(fieldNames)),
+	}
+	for ord := 0; ord < n && dec.err == nil; ord++ {
+		doc := decodeDocument(dec)
+		seg.documents[ord] = doc
+		seg.docIDs[ord] = doc.ID
+		seg.ordinals[doc.ID] = uint32(ord)
+	}
+
+	terms := make([]string, dec.count())
+	seg.docFreq = make(map[string]int, len(terms))
+	for i := range terms {
+		terms[i] = dec.string()
+		seg.docFreq[terms[i]] = int(dec.uvarint())
+	}
+	term := func(id uint64) string {
+		if id >= uint64(len(terms)) {
+			dec.fail()
+			return ""
+		}
+		return terms[id]
+	}
+
+	for ord := 0; ord < n && dec.err == nil; ord++ {
+		docTerms := make([]string, dec.count())
+		var id uint64
+		for i := range docTerms {
+			id += dec.uvarint()
+			docTerms[i] = term(id)
+		}
+		seg.docTerms[ord] = docTerms
+	}
+
+	for _, name := range fieldNames {
+		f := &segmentField{totalTokens: int(dec.uvarint()), lengths: make([]uint32, n)}
+		for ord := range f.lengths {
+			f.lengths[ord] = uint32(dec.uvarint())
+		}
+		lists := dec.count()
+		f.postings = make(map[string]*postingList, lists)
+		for i := 0; i < lists && dec.err == nil; i++ {
+			key := term(dec.uvarint())
+			list := &postingList{count: int(dec.uvarint())}
+			size := dec.count()
+			list.data = dec.data[:size:size]
+			dec.data = dec.data[size:]
+			list.skips = make([]skipEntry, dec.count())
+			for j := range list.skips {
+				list.skips[j] = skipEntry{doc: uint32(dec.uvarint()), offset: uint32(dec.uvarint())}
+			}
+			f.postings[key] = list
+		}
+		seg.fields[name] = f
+	}
+	seg.postingBytes = int(dec.uvarint())
+	seg.rawBytes = int(dec.uvarint())
+	return newSegmentRefWithDeleted(seg, dec.strings())
+}
+
+// RestoreIndex installs the named index section into idx, which must be empty and
+// index the same fields with the same analyzer. Postings and statistics are used as
+// stored, so nothing is re-analyzed. It reports false when the snapshot has no such
+// section, as with snapshots written before indexes were persisted; callers then
+// re-index Documents.
+func (l *LoadedSnapshot) RestoreIndex(name string, idx *InvertedIndex) (bool, error) {
+	image, ok := l.indexes[name]
+	if !ok {
+		return false, nil
+	}
+	if image.analyzer != idx.Analyzer.Name() {
+		return false, fmt.Errorf("snapshot index %q was analyzed with %q, want %q", name, image.analyzer, idx.Analyzer.Name())
+	}
+	if !slices.Equal(image.fieldNames, idx.fieldNames) {
+		return false, fmt.Errorf("snapshot index %q has fields %v, want %v", name, image.fieldNames, idx.fieldNames)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if len(idx.buffer.documents) > 0 || len(idx.current.Load().refs) > 0 {
+		return false, fmt.Errorf("restore index %q: index is not empty", name)
+	}
+	for _, ref := range image.refs {
+		ref.seg.id = idx.nextID.Add(1)
+		for _, id := range ref.seg.docIDs {
+			if !ref.isDeleted(id) {
+				idx.locations[id] = ref.seg
+				idx.linkLocked(ref.seg.documents[ref.seg.ordinals[id]])
+			}
+		}
+	}
+	idx.publishLocked(image.refs)
+	return true, nil
+}
+
+func encodeDocument(enc *sectionEncoder, doc *docs.Document) {
+	enc.string(doc.ID)
+	enc.string(doc.URL)
+	enc.string(doc.Title)
+	enc.string(doc.Content)
+	enc.strings(doc.Tokens)
+	enc.string(doc.Type)
+	enc.string(doc.ParentID)
+	if doc.FetchedAt.IsZero() {
+		enc.varint(0)
+	} else {
+		enc.varint(doc.FetchedAt.UnixNano())
+	}
+	if doc.Image != nil {
+		enc.uvarint(1)
+		enc.string(doc.Image.Alt)
+		enc.string(doc.Image.Caption)
+		enc.string(doc.Image.PageURL)
+		enc.string(doc.Image.PageTitle)
+	} else {
+		enc.uvarint(0)
+	}
+	keys := make([]string, 0, len(doc.Metadata))
+	for key := range doc.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	enc.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		enc.string(key)
+		enc.string(doc.Metadata[key])
+	}
+}
+
+func decodeDocument(dec *sectionDecoder) *docs.Document {
+	doc := &docs.Document{
+		ID:       dec.string(),
+		URL:      dec.string(),
+		Title:    dec.string(),
+		Content:  dec.string(),
+		Tokens:   dec.strings(),
+		Type:     dec.string(),
+		ParentID: dec.string(),
+	}
+	if nanos := dec.varint(); nanos != 0 {
+		doc.FetchedAt = time.Unix(0, nanos).UTC()
+	}
+	if dec.uvarint() == 1 {
+		doc.Image = &docs.ImageInfo{Alt: dec.string(), Caption: dec.string(), PageURL: dec.string(), PageTitle: dec.string()}
+	}
+	if n := dec.count(); n > 0 {
+		doc.Metadata = make(map[string]string, n)
+		for j := 0; j < n; j++ {
+			key := dec.string()
+			doc.Metadata[key] = dec.string()
+		}
+	}
+	return doc
+}
@@ -0,0 +1,270 @@
+package index_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	fetched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pages := index.NewInvertedIndex()
+	pages.AddDocument(&docs.Document{ID: "a", URL: "https://example.com/a", Title: "Kafka", Content: "kafka brokers and kafka topics", FetchedAt: fetched, Type: docs.TypePage, Metadata: map[string]string{"team": "search"}})
+	pages.AddDocument(&docs.Document{ID: "c", URL: "https://example.com/c", Content: "kafka consumers"})
+	pages.Refresh()
+	pages.AddDocument(&docs.Document{ID: "d", URL: "https://example.com/d", Content: "raft consensus"})
+	pages.RemoveDocument("c")
+	images := index.NewInvertedIndex()
+	images.AddDocument(&docs.Document{ID: "b", URL: "https://example.com/b.png", Type: docs.TypeImage, ParentID: "a", Image: &docs.ImageInfo{Alt: "logo", Caption: "Our logo", PageURL: "https://example.com/a", PageTitle: "A"}})
+
+	var w index.SnapshotWriter
+	w.AddIndex(index.SnapshotPages, pages)
+	w.AddIndex(index.SnapshotImages, images)
+	w.AddBlob("extra", []byte("payload"))
+	if err := w.Write(path); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	if loaded.Version != index.SnapshotVersion || loaded.Path != path || len(loaded.Documents) != 3 {
+		t.Fatalf("unexpected snapshot: %+v", loaded)
+	}
+	if blob, ok := loaded.Blob("extra"); !ok || string(blob) != "payload" {
+		t.Fatalf("unexpected blob %q", blob)
+	}
+
+	restored := index.NewInvertedIndex()
+	if ok, err := loaded.RestoreIndex(index.SnapshotPages, restored); !ok || err != nil {
+		t.Fatalf("restore pages: %v, %v", ok, err)
+	}
+	if got := restored.DocumentCount(); got != 2 {
+		t.Fatalf("expected 2 restored documents, got %d", got)
+	}
+	if got := restored.DocumentFrequency("kafka"); got != 1 {
+		t.Fatalf("expected deleted document to stay deleted, kafka df %d", got)
+	}
+	posting, ok := restored.FieldPosting(index.FieldBody, "kafka", "a")
+	if !ok || posting.TF != 2 || posting.Positions[1] != 3 {
+		t.Fatalf("unexpected restored posting: %+v", posting)
+	}
+	if got, want := restored.AverageFieldLength(index.FieldBody), pages.AverageFieldLength(index.FieldBody); got != want {
+		t.Fatalf("expected average body length %v, got %v", want, got)
+	}
+	a, _ := restored.Document("a")
+	if !a.FetchedAt.Equal(fetched) || a.Metadata["team"] != "search" || a.Title != "Kafka" {
+		t.Fatalf("unexpected restored document: %+v", a)
+	}
+
+	restored.AddDocument(&docs.Document{ID: "a", Content: "rewritten"})
+	if got := restored.DocumentFrequency("kafka"); got != 0 {
+		t.Fatalf("expected update after restore to replace a, kafka df %d", got)
+	}
+
+	restoredImages := index.NewInvertedIndex()
+	if ok, err := loaded.RestoreIndex(index.SnapshotImages, restoredImages); !ok || err != nil {
+		t.Fatalf("restore images: %v, %v", ok, err)
+	}
+	b, ok := restoredImages.Document("b")
+	if !ok || b.Image == nil || b.Image.Caption != "Our logo" || b.ParentID != "a" || !b.IsImage() {
+		t.Fatalf("unexpected image document: %+v", b)
+	}
+	if removed := restoredImages.RemoveByParent("a"); removed != 1 {
+		t.Fatalf("expected the restored image to be found by its parent, removed %d", removed)
+	}
+	if ok, _ := loaded.RestoreIndex("missing", index.NewInvertedIndex()); ok {
+		t.Fatalf("expected missing index section to report false")
+	}
+	english := index.NewInvertedIndex()
+	english.Analyzer = analysis.English()
+	if ok, err := loaded.RestoreIndex(index.SnapshotPages, english); ok || err == nil {
+		t.Fatalf("expected analyzer mismatch to be rejected, got %v, %v", ok, err)
+	}
+}
+
+func TestShardedSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	pages := index.NewShardedIndex(3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		pages.AddDocument(&docs.Document{ID: id, Content: "kafka " + id})
+	}
+	var w index.SnapshotWriter
+	w.AddShardedIndex(index.SnapshotPages, pages)
+	if err := w.Write(path); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	if len(loaded.Documents) != 5 {
+		t.Fatalf("expected 5 documents, got %d", len(loaded.Documents))
+	}
+
+	restored := index.NewShardedIndex(3)
+	if ok, err := loaded.RestoreShardedIndex(index.SnapshotPages, restored); !ok || err != nil {
+		t.Fatalf("restore shards: %v, %v", ok, err)
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if _, ok := restored.Shard(id).Document(id); !ok {
+			t.Fatalf("expected %s in its shard after restore", id)
+		}
+	}
+	if ok, err := loaded.RestoreShardedIndex(index.SnapshotPages, index.NewShardedIndex(2)); ok || err == nil {
+		t.Fatalf("expected a different shard count to be rejected, got %v, %v", ok, err)
+	}
+	if ok, err := loaded.RestoreShardedIndex(index.SnapshotImages, index.NewShardedIndex(3)); ok || err != nil {
+		t.Fatalf("expected a missing index to report false without error, got %v, %v", ok, err)
+	}
+}
+
+func TestLoadSnapshotFallsBackToPrevious(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "old", Content: "old"})
+	if err := index.WriteSnapshot(idx, path); err != nil {
+		t.Fatalf("write first snapshot: %v", err)
+	}
+	idx.RemoveDocument("old")
+	idx.AddDocument(&docs.Document{ID: "new", Content: "new"})
+	if err := index.WriteSnapshot(idx, path); err != nil {
+		t.Fatalf("write second snapshot: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("corrupt snapshot: %v", err)
+	}
+	if _, err := index.ReadSnapshot(path); !errors.Is(err, index.ErrSnapshotCorrupt) {
+		t.Fatalf("expected checksum failure, got %v", err)
+	}
+
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("expected fallback, got %v", err)
+	}
+	if loaded.Path != index.PreviousSnapshotPath(path) || loaded.PrimaryErr == nil {
+		t.Fatalf("expected previous snapshot to be used, got %+v", loaded)
+	}
+	if len(loaded.Documents) != 1 || loaded.Documents[0].ID != "old" {
+		t.Fatalf("unexpected fallback documents: %+v", loaded.Documents)
+	}
+
+	if err := os.WriteFile(path, data[:len(data)/2], 0o644); err != nil {
+		t.Fatalf("truncate snapshot: %v", err)
+	}
+	if _, err := index.ReadSnapshot(path); !errors.Is(err, index.ErrSnapshotCorrupt) {
+		t.Fatalf("expected truncation to be detected, got %v", err)
+	}
+}
+
+func TestLoadLegacyJSONSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot.json")
+	legacy := `{
+  "documents": [
+    {"id": "doc1", "url": "https://example.com", "title": "Example", "tokens": ["hello"], "content": "hello", "metadata": {"lang": "en"}}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write legacy snapshot: %v", err)
+	}
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load legacy snapshot: %v", err)
+	}
+	if loaded.Version != 0 || len(loaded.Documents) != 1 || loaded.Documents[0].Metadata["lang"] != "en" {
+		t.Fatalf("unexpected legacy snapshot: %+v", loaded)
+	}
+
+	idx := index.NewInvertedIndex()
+	for _, doc := range loaded.Documents {
+		idx.AddDocument(doc)
+	}
+	if err := index.WriteSnapshot(idx, path); err != nil {
+		t.Fatalf("rewrite snapshot: %v", err)
+	}
+	migrated, err := index.ReadSnapshot(path)
+	if err != nil || migrated.Version != index.SnapshotVersion || migrated.Documents[0].Title != "Example" {
+		t.Fatalf("expected binary snapshot after rewrite, got %+v, %v", migrated, err)
+	}
+}
+
+func TestLoadSnapshotReadsLegacyPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	if _, err := index.LoadSnapshot(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing snapshot, got %v", err)
+	}
+	legacy := index.LegacySnapshotPath(path)
+	if err := os.WriteFile(legacy, []byte(`{"documents": [{"id": "doc1", "content": "hello"}]}`), 0o644); err != nil {
+		t.Fatalf("write legacy snapshot: %v", err)
+	}
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil || loaded.Path != legacy || len(loaded.Documents) != 1 {
+		t.Fatalf("expected the snapshot at the legacy path, got %+v, %v", loaded, err)
+	}
+
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "doc2", Content: "current"})
+	if err := index.WriteSnapshot(idx, path); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+	loaded, err = index.LoadSnapshot(path)
+	if err != nil || loaded.Path != path || loaded.Documents[0].ID != "doc2" {
+		t.Fatalf("expected the new path to win once written, got %+v, %v", loaded, err)
+	}
+}
+
+func benchmarkSnapshot(b *testing.B) string {
+	path := filepath.Join(b.TempDir(), "index.snapshot")
+	idx := index.NewInvertedIndex()
+	for _, doc := range benchmarkDocuments(5000) {
+		idx.AddDocument(doc)
+	}
+	if err := index.WriteSnapshot(idx, path); err != nil {
+		b.Fatalf("write snapshot: %v", err)
+	}
+	return path
+}
+
+func BenchmarkSnapshotRestore(b *testing.B) {
+	path := benchmarkSnapshot(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loaded, err := index.LoadSnapshot(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := loaded.RestoreIndex(index.SnapshotPages, index.NewInvertedIndex()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSnapshotReindex(b *testing.B) {
+	path := benchmarkSnapshot(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loaded, err := index.LoadSnapshot(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		idx := index.NewInvertedIndex()
+		for _, doc := range loaded.Documents {
+			idx.AddDocument(doc)
+		}
+		idx.Refresh()
+	}
+}
@@ -1,21 +1,11 @@
 package index
 
-import (
-	"regexp"
-	"strings"
-)
+import "github.com/eshwanth/distributed-search-engine/internal/analysis"
 
-var tokenRegexp = regexp.MustCompile(`[a-zA-Z0-9]+`)
+var standardAnalyzer = analysis.Standard()
 
-// Tokenize lowercases the input and returns word tokens suitable for indexing.
+// Tokenize returns the terms of text under the standard analyzer, which is what
+// NewInvertedIndex uses unless its Analyzer is replaced.
 func Tokenize(text string) []string {
-	matches := tokenRegexp.FindAllString(text, -1)
-	if len(matches) == 0 {
-		return nil
-	}
-	tokens := make([]string, 0, len(matches))
-	for _, m := range matches {
-		tokens = append(tokens, strings.ToLower(m))
-	}
-	return tokens
+	return standardAnalyzer.Analyze(text)
 }
@@ -1,11 +1,143 @@
 package pipeline
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
 )
 
+// Event types.
+const (
+	EventUpsert = "upsert"
+	EventDelete = "delete"
+)
+
+// SchemaVersion is the newest event schema this package reads and the one it writes.
+// Version 0 is the bare JSON document published before events had an envelope.
+const SchemaVersion = 1
+
+// Codecs that events can be written with. Readers detect the codec of each message.
+const (
+	CodecJSON   = "json"
+	CodecBinary = "binary"
+)
+
+// binaryMagic opens every binary event. JSON events start with '{', so the two codecs
+// can share a topic.
+var binaryMagic = []byte{0xd5, 'E'}
+
+// Event is the envelope every document change is published in.
+type Event struct {
+	Type          string `json:"type"`
+	SchemaVersion int    `json:"schema_version"`
+	// DocID identifies the document, which for deletes is all the event carries.
+	DocID string `json:"doc_id"`
+	// DocVersion identifies the version of the document the producer published. It is
+	// informational: consumers apply events in partition order and do not compare
+	// versions, so a replayed or late event overwrites whatever it finds.
+	DocVersion int64 `json:"doc_version,omitempty"`
+	// Source names the producer, such as "orchestrator" or "importer".
+	Source   string         `json:"source,omitempty"`
+	Time     time.Time      `json:"time"`
+	Document *docs.Document `json:"document,omitempty"`
+}
+
+// NewEvent wraps a document change from source. Deleted documents become delete events,
+// although KafkaSink publishes deletes as tombstones so that compaction drops them. The
+// document version is its fetch time, or the current time when it has none.
+func NewEvent(doc *docs.Document, source string) Event {
+	now := time.Now().UTC()
+	event := Event{
+		Type:          EventUpsert,
+		SchemaVersion: SchemaVersion,
+		DocID:         doc.ID,
+		DocVersion:    now.UnixNano(),
+		Source:        source,
+		Time:          now,
+		Document:      doc,
+	}
+	if !doc.FetchedAt.IsZero() {
+		event.DocVersion = doc.FetchedAt.UnixNano()
+	}
+	if doc.Deleted {
+		event.Type, event.Document = EventDelete, nil
+	}
+	return event
+}
+
+// Doc returns the document the event applies: the carried document for upserts and a
+// tombstone for deletes.
+func (e Event) Doc() (*docs.Document, error) {
+	if e.DocID == "" {
+		return nil, errors.New("event without document ID")
+	}
+	switch e.Type {
+	case EventUpsert:
+		if e.Document == nil {
+			return nil, errors.New("upsert event without document")
+		}
+		doc := e.Document
+		doc.ID = e.DocID
+		return doc, nil
+	case EventDelete:
+		return docs.Tombstone(e.DocID), nil
+	default:
+		return nil, fmt.Errorf("unknown event type %q", e.Type)
+	}
+}
+
+// MarshalEvent encodes an event with the named codec, JSON when codec is empty.
+func MarshalEvent(event Event, codec string) ([]byte, error) {
+	switch codec {
+	case "", CodecJSON:
+		return json.Marshal(event)
+	case CodecBinary:
+		return marshalBinaryEvent(event), nil
+	default:
+		return nil, fmt.Errorf("unknown event codec %q", codec)
+	}
+}
+
+// UnmarshalEvent decodes an event written with either codec, or a bare JSON document
+// from before the envelope, which is read as an upsert at schema version 0. Events from
+// a newer schema are rejected rather than misread.
+func UnmarshalEvent(payload []byte) (Event, error) {
+	if bytes.HasPrefix(payload, binaryMagic) {
+		return unmarshalBinaryEvent(payload[len(binaryMagic):])
+	}
+	var probe struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return Event{}, err
+	}
+	if probe.SchemaVersion == 0 {
+		doc, err := UnmarshalDocument(payload)
+		if err != nil {
+			return Event{}, err
+		}
+		event := Event{Type: EventUpsert, DocID: doc.ID, Document: doc}
+		if doc.Deleted {
+			event.Type, event.Document = EventDelete, nil
+		}
+		return event, nil
+	}
+	if probe.SchemaVersion > SchemaVersion {
+		return Event{}, fmt.Errorf("event schema version %d is newer than %d", probe.SchemaVersion, SchemaVersion)
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
 // MarshalDocument encodes a document into JSON for transport.
 func MarshalDocument(doc *docs.Document) ([]byte, error) {
 	return json.Marshal(doc)
@@ -19,3 +151,180 @@ func UnmarshalDocument(payload []byte) (*docs.Document, error) {
 	}
 	return &doc, nil
 }
+
+// marshalBinaryEvent writes the event as varints and length-prefixed strings after
+// binaryMagic and the schema version. Two empty strings after the time are reserved for
+// trace context.
+func marshalBinaryEvent(event Event) []byte {
+	var e eventEncoder
+	e.buf = append(e.buf, binaryMagic...)
+	e.uvarint(SchemaVersion)
+	e.string(event.Type)
+	e.string(event.DocID)
+	e.varint(event.DocVersion)
+	e.string(event.Source)
+	e.time(event.Time)
+	e.string("")
+	e.string("")
+	doc := event.Document
+	if doc == nil {
+		e.uvarint(0)
+		return e.buf
+	}
+	e.uvarint(1)
+	e.string(doc.URL)
+	e.string(doc.Title)
+	e.string(doc.Content)
+	e.uvarint(uint64(len(doc.Tokens)))
+	for _, token := range doc.Tokens {
+		e.string(token)
+	}
+	e.time(doc.FetchedAt)
+	e.string(doc.Type)
+	e.string(doc.ParentID)
+	if doc.Image != nil {
+		e.uvarint(1)
+		e.string(doc.Image.Alt)
+		e.string(doc.Image.Caption)
+		e.string(doc.Image.PageURL)
+		e.string(doc.Image.PageTitle)
+	} else {
+		e.uvarint(0)
+	}
+	keys := make([]string, 0, len(doc.Metadata))
+	for key := range doc.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	e.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		e.string(key)
+		e.string(doc.Metadata[key])
+	}
+	return e.buf
+}
+
+func unmarshalBinaryEvent(data []byte) (Event, error) {
+	d := eventDecoder{data: data}
+	version := d.uvarint()
+	if d.err == nil && (version == 0 || version > SchemaVersion) {
+		return Event{}, fmt.Errorf("binary event schema version %d is not supported", version)
+	}
+	event := Event{
+		SchemaVersion: int(version),
+		Type:          d.string(),
+		DocID:         d.string(),
+		DocVersion:    d.varint(),
+		Source:        d.string(),
+		Time:          d.time(),
+	}
+	d.string()
+	d.string()
+	if d.uvarint() == 1 {
+		doc := &docs.Document{ID: event.DocID, URL: d.string(), Title: d.string(), Content: d.string()}
+		if n := d.count(); n > 0 {
+			doc.Tokens = make([]string, n)
+			for i := range doc.Tokens {
+				doc.Tokens[i] = d.string()
+			}
+		}
+		doc.FetchedAt = d.time()
+		doc.Type = d.string()
+		doc.ParentID = d.string()
+		if d.uvarint() == 1 {
+			doc.Image = &docs.ImageInfo{Alt: d.string(), Caption: d.string(), PageURL: d.string(), PageTitle: d.string()}
+		}
+		if n := d.count(); n > 0 {
+			doc.Metadata = make(map[string]string, n)
+			for i := 0; i < n; i++ {
+				key := d.string()
+				doc.Metadata[key] = d.string()
+			}
+		}
+		event.Document = doc
+	}
+	if d.err != nil {
+		return Event{}, fmt.Errorf("decode binary event: %w", d.err)
+	}
+	return event, nil
+}
+
+type eventEncoder struct {
+	buf []byte
+}
+
+func (e *eventEncoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+
+func (e *eventEncoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *eventEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// time writes t in Unix nanoseconds, or zero for the zero time.
+func (e *eventEncoder) time(t time.Time) {
+	if t.IsZero() {
+		e.varint(0)
+		return
+	}
+	e.varint(t.UnixNano())
+}
+
+// eventDecoder reads values written by eventEncoder. The first failure is sticky.
+type eventDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *eventDecoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("unexpected end of event")
+	}
+	d.data = nil
+}
+
+func (d *eventDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *eventDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// count reads a length that cannot exceed the remaining data.
+func (d *eventDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *eventDecoder) string() string {
+	n := d.count()
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+func (d *eventDecoder) time() time.Time {
+	nanos := d.varint()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
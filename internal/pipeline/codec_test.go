@@ -0,0 +1,72 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+)
+
+func TestEventCodecsRoundTrip(t *testing.T) {
+	page := &docs.Document{
+		ID:        "page",
+		URL:       "https://example.com/kafka",
+		Title:     "Kafka",
+		Content:   "kafka consumer groups",
+		FetchedAt: time.Date(2025, 10, 6, 12, 0, 0, 0, time.UTC),
+		Type:      docs.TypeImage,
+		ParentID:  "parent",
+		Image:     &docs.ImageInfo{Alt: "diagram", Caption: "groups"},
+		Metadata:  map[string]string{"team": "search"},
+	}
+	for _, codec := range []string{pipeline.CodecJSON, pipeline.CodecBinary} {
+		for _, doc := range []*docs.Document{page, docs.Tombstone("gone")} {
+			event := pipeline.NewEvent(doc, "orchestrator")
+			payload, err := pipeline.MarshalEvent(event, codec)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", codec, err)
+			}
+			decoded, err := pipeline.UnmarshalEvent(payload)
+			if err != nil {
+				t.Fatalf("%s: unmarshal: %v", codec, err)
+			}
+			if decoded.Type != event.Type || decoded.SchemaVersion != pipeline.SchemaVersion || decoded.DocVersion != event.DocVersion ||
+				decoded.Source != "orchestrator" || !decoded.Time.Equal(event.Time) {
+				t.Fatalf("%s: envelope mismatch: %+v vs %+v", codec, decoded, event)
+			}
+			got, err := decoded.Doc()
+			if err != nil {
+				t.Fatalf("%s: doc: %v", codec, err)
+			}
+			if !reflect.DeepEqual(got, doc) {
+				t.Fatalf("%s: expected %+v, got %+v", codec, doc, got)
+			}
+		}
+	}
+}
+
+func TestUnmarshalEventReadsOlderAndRejectsNewerSchemas(t *testing.T) {
+	bare, err := pipeline.MarshalDocument(&docs.Document{ID: "legacy", Title: "Raft", Type: docs.TypePage})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	event, err := pipeline.UnmarshalEvent(bare)
+	if err != nil {
+		t.Fatalf("unmarshal bare document: %v", err)
+	}
+	if doc, err := event.Doc(); err != nil || event.SchemaVersion != 0 || event.Type != pipeline.EventUpsert || doc.ID != "legacy" || doc.Title != "Raft" {
+		t.Fatalf("expected a schema 0 upsert of the bare document, got %+v, %v", event, err)
+	}
+
+	deleted, _ := pipeline.MarshalDocument(docs.Tombstone("old"))
+	if event, err := pipeline.UnmarshalEvent(deleted); err != nil || event.Type != pipeline.EventDelete || event.DocID != "old" {
+		t.Fatalf("expected a bare deleted document to read as a delete, got %+v, %v", event, err)
+	}
+
+	if _, err := pipeline.UnmarshalEvent([]byte(`{"type":"upsert","schema_version":99,"doc_id":"x"}`)); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("expected a newer schema to be rejected, got %v", err)
+	}
+}
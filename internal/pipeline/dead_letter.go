@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to a dead-lettered message, next to the original message's headers.
+const (
+	HeaderDeadLetterError     = "dlq-error"
+	HeaderDeadLetterStage     = "dlq-stage"
+	HeaderDeadLetterTopic     = "dlq-topic"
+	HeaderDeadLetterPartition = "dlq-partition"
+	HeaderDeadLetterOffset    = "dlq-offset"
+	HeaderDeadLetterAttempts  = "dlq-attempts"
+	HeaderDeadLetterFailedAt  = "dlq-failed-at"
+)
+
+// Stages at which a message can fail.
+const (
+	StageDecode  = "decode"
+	StageHandler = "handler"
+)
+
+// DeadLetter is a message that could not be processed, as read back from a
+// dead-letter topic.
+type DeadLetter struct {
+	// Partition and Offset locate the message in the dead-letter topic.
+	Partition int
+	Offset    int64
+	// Message is the original message, with its own headers and without the dead-letter
+	// metadata below.
+	Message kafka.Message
+	// Topic, SourcePartition, and SourceOffset locate the original message.
+	Topic           string
+	SourcePartition int
+	SourceOffset    int64
+	Stage           string
+	Error           string
+	Attempts        int
+	FailedAt        time.Time
+}
+
+// DeadLetterMessage wraps a message that failed at stage after attempts tries, ready to
+// be published to a dead-letter topic. The key, value, and headers are kept so that
+// the message can be replayed unchanged.
+func DeadLetterMessage(m kafka.Message, stage string, attempts int, cause error) kafka.Message {
+	headers := append([]kafka.Header(nil), m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDeadLetterStage, Value: []byte(stage)},
+		kafka.Header{Key: HeaderDeadLetterTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderDeadLetterPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDeadLetterFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+	return kafka.Message{Key: m.Key, Value: m.Value, Headers: headers}
+}
+
+// ParseDeadLetter reads the metadata of a message from a dead-letter topic.
+func ParseDeadLetter(m kafka.Message) DeadLetter {
+	d := DeadLetter{Partition: m.Partition, Offset: m.Offset, Message: kafka.Message{Key: m.Key, Value: m.Value}}
+	for _, h := range m.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case HeaderDeadLetterError:
+			d.Error = value
+		case HeaderDeadLetterStage:
+			d.Stage = value
+		case HeaderDeadLetterTopic:
+			d.Topic = value
+		case HeaderDeadLetterPartition:
+			d.SourcePartition, _ = strconv.Atoi(value)
+		case HeaderDeadLetterOffset:
+			d.SourceOffset, _ = strconv.ParseInt(value, 10, 64)
+		case HeaderDeadLetterAttempts:
+			d.Attempts, _ = strconv.Atoi(value)
+		case HeaderDeadLetterFailedAt:
+			d.FailedAt, _ = time.Parse(time.RFC3339Nano, value)
+		default:
+			if !strings.HasPrefix(h.Key, "dlq-") {
+				d.Message.Headers = append(d.Message.Headers, h)
+			}
+		}
+	}
+	return d
+}
+
+// ReadTopic passes every message currently in topic to fn, partition by partition, and
+// returns once it reaches the offsets that were the end of each partition when it
+// started. It reads without a consumer group and commits nothing.
+func ReadTopic(ctx context.Context, brokers []string, topic string, fn func(kafka.Message) error) error {
+	partitions, err := lookupPartitions(ctx, brokers, topic)
+	if err != nil {
+		return err
+	}
+	for _, partition := range partitions {
+		if err := readPartition(ctx, brokers, topic, partition, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPartition(ctx context.Context, brokers []string, topic string, partition kafka.Partition, fn func(kafka.Message) error) error {
+	conn, err := kafka.DialLeader(ctx, "tcp", fmt.Sprintf("%s:%d", partition.Leader.Host, partition.Leader.Port), topic, partition.ID)
+	if err != nil {
+		return fmt.Errorf("dial leader of partition %d: %w", partition.ID, err)
+	}
+	first, last, err := conn.ReadOffsets()
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("read offsets of partition %d: %w", partition.ID, err)
+	}
+	if first >= last {
+		return nil
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, Partition: partition.ID})
+	defer reader.Close()
+	if err := reader.SetOffset(first); err != nil {
+		return err
+	}
+	for {
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		if m.Offset+1 >= last {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package pipeline_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	original := kafka.Message{
+		Topic:     "documents",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("doc-1"),
+		Value:     []byte(`{"ID":`),
+		Headers:   []kafka.Header{{Key: "trace", Value: []byte("abc")}},
+	}
+	wrapped := pipeline.DeadLetterMessage(original, pipeline.StageHandler, 4, errors.New("index unavailable"))
+	wrapped.Partition, wrapped.Offset = 0, 7
+
+	d := pipeline.ParseDeadLetter(wrapped)
+	if d.Partition != 0 || d.Offset != 7 {
+		t.Fatalf("expected the dead-letter position, got %d/%d", d.Partition, d.Offset)
+	}
+	if d.Topic != "documents" || d.SourcePartition != 3 || d.SourceOffset != 42 {
+		t.Fatalf("expected the source position, got %s %d/%d", d.Topic, d.SourcePartition, d.SourceOffset)
+	}
+	if d.Stage != pipeline.StageHandler || d.Error != "index unavailable" || d.Attempts != 4 || d.FailedAt.IsZero() {
+		t.Fatalf("unexpected failure metadata %+v", d)
+	}
+	if string(d.Message.Key) != "doc-1" || string(d.Message.Value) != `{"ID":` {
+		t.Fatalf("expected the original key and value, got %q %q", d.Message.Key, d.Message.Value)
+	}
+	if len(d.Message.Headers) != 1 || d.Message.Headers[0].Key != "trace" {
+		t.Fatalf("expected only the original headers on replay, got %+v", d.Message.Headers)
+	}
+}
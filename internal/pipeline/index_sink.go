@@ -10,8 +10,10 @@ import (
 
 // IndexSink writes documents from the crawler into the inverted index.
 type IndexSink struct {
-	idx *index.InvertedIndex
-	mu  sync.Mutex
+	// Images receives image documents; they are dropped when it is nil.
+	Images *index.InvertedIndex
+	idx    *index.InvertedIndex
+	mu     sync.Mutex
 }
 
 // NewIndexSink creates a sink bound to the provided index.
@@ -19,10 +21,24 @@ func NewIndexSink(idx *index.InvertedIndex) *IndexSink {
 	return &IndexSink{idx: idx}
 }
 
-// Consume indexes the received document.
+// Consume indexes the received document, or removes it when it is a tombstone.
 func (s *IndexSink) Consume(doc *docs.Document) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if doc.Deleted {
+		s.idx.RemoveDocument(doc.ID)
+		if s.Images != nil {
+			s.Images.RemoveDocument(doc.ID)
+			s.Images.RemoveByParent(doc.ID)
+		}
+		return
+	}
+	if doc.IsImage() {
+		if s.Images != nil {
+			s.Images.AddDocument(doc)
+		}
+		return
+	}
 	s.idx.AddDocument(doc)
 }
 
@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
@@ -12,9 +13,14 @@ import (
 
 // IndexUpdater consumes documents and updates both lexical and semantic indexes.
 type IndexUpdater struct {
-	Consumer        DocumentConsumer
-	Index           *index.InvertedIndex
-	Semantic        *semantic.Index
+	Consumer DocumentConsumer
+	Index    *index.ShardedIndex
+	Semantic *semantic.Index
+	// Images receives image documents; they are dropped when it is nil.
+	Images *index.InvertedIndex
+	// Owns, when set, limits pages and semantic vectors to the documents it accepts,
+	// so a cluster node only indexes its own shards. Images are always kept.
+	Owns            func(docID string) bool
 	SnapshotPath    string
 	SnapshotEvery   time.Duration
 	Logger          telemetry.Logger
@@ -27,23 +33,69 @@ func (u *IndexUpdater) Run(ctx context.Context) error {
 		return nil
 	}
 	return u.Consumer.Consume(ctx, func(doc *docs.Document) error {
-		u.Index.AddDocument(doc)
-		if u.Semantic != nil {
-			u.Semantic.AddDocument(doc)
+		if doc.Deleted {
+			u.remove(doc.ID)
+			telemetry.IncIndexDeletes()
+		} else if doc.IsImage() {
+			if u.Images != nil {
+				u.Images.AddDocument(doc)
+			}
+		} else if u.Owns == nil || u.Owns(doc.ID) {
+			u.Index.AddDocument(doc)
+			if u.Semantic != nil {
+				u.Semantic.AddDocument(doc)
+			}
+			telemetry.IncIndexUpdates()
 		}
-		telemetry.IncIndexUpdates()
 
+		// A snapshot that cannot be written is fatal once the consumer's retries run out:
+		// the process stops and resumes from the last snapshot rather than running on
+		// with nothing to recover from.
 		if u.SnapshotPath != "" && u.SnapshotEvery > 0 {
 			now := time.Now()
 			if u.lastSnapshotRun.IsZero() || now.Sub(u.lastSnapshotRun) >= u.SnapshotEvery {
-				if err := index.WriteSnapshot(u.Index, u.SnapshotPath); err != nil {
+				if err := u.writeSnapshot(); err != nil {
 					u.Logger.Error("write_snapshot_failed", err, "path", u.SnapshotPath)
-				} else {
-					u.Logger.Info("snapshot_written", "path", u.SnapshotPath)
-					u.lastSnapshotRun = now
+					return &FatalError{Err: fmt.Errorf("write snapshot %s: %w", u.SnapshotPath, err)}
 				}
+				u.Logger.Info("snapshot_written", "path", u.SnapshotPath)
+				u.lastSnapshotRun = now
 			}
 		}
 		return nil
 	})
 }
+
+// remove deletes a document, and any images found on it, from every index.
+func (u *IndexUpdater) remove(id string) {
+	u.Index.RemoveDocument(id)
+	if u.Semantic != nil {
+		u.Semantic.RemoveDocument(id)
+	}
+	if u.Images != nil {
+		u.Images.RemoveDocument(id)
+		u.Images.RemoveByParent(id)
+	}
+}
+
+// writeSnapshot persists the prebuilt lexical, image, and semantic indexes so readers
+// can load them without re-indexing, with the consumer's position when it reports one.
+// Snapshots are written between documents, so the position matches their contents.
+func (u *IndexUpdater) writeSnapshot() error {
+	var w index.SnapshotWriter
+	if consumer, ok := u.Consumer.(PositionedConsumer); ok {
+		w.AddPosition(consumer.Position())
+	}
+	w.AddShardedIndex(index.SnapshotPages, u.Index)
+	if u.Images != nil {
+		w.AddIndex(index.SnapshotImages, u.Images)
+	}
+	if u.Semantic != nil {
+		data, err := u.Semantic.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		w.AddBlob(index.SnapshotSemantic, data)
+	}
+	return w.Write(u.SnapshotPath)
+}
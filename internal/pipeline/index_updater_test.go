@@ -0,0 +1,141 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/pipeline"
+	"github.com/eshwanth/distributed-search-engine/internal/semantic"
+)
+
+type sliceConsumer struct {
+	docs []*docs.Document
+}
+
+func (c *sliceConsumer) Consume(ctx context.Context, handler pipeline.DocumentHandler) error {
+	for _, doc := range c.docs {
+		if err := handler(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *sliceConsumer) Close() error { return nil }
+
+// positionedConsumer reports the document it is handling as the last one read, the way
+// KafkaConsumer advances its offsets before calling the handler.
+type positionedConsumer struct {
+	sliceConsumer
+	next int64
+}
+
+func (c *positionedConsumer) Consume(ctx context.Context, handler pipeline.DocumentHandler) error {
+	for i, doc := range c.docs {
+		c.next = int64(i) + 1
+		if err := handler(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *positionedConsumer) Position() index.StreamPosition {
+	return index.StreamPosition{Topic: "documents", Offsets: map[int]int64{0: c.next}}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+
+func TestIndexUpdaterAppliesTombstones(t *testing.T) {
+	consumer := &sliceConsumer{docs: []*docs.Document{
+		{ID: "page", Content: "kafka consumer groups"},
+		{ID: "img", Type: docs.TypeImage, ParentID: "page", Content: "consumer group diagram"},
+		{ID: "keep", Content: "raft consensus"},
+		docs.Tombstone("page"),
+	}}
+	updater := &pipeline.IndexUpdater{
+		Consumer: consumer,
+		Index:    index.NewShardedIndex(2),
+		Semantic: semantic.NewIndex(semantic.Options{Dimension: 32, HyperplaneCount: 8, Seed: 1}),
+		Images:   index.NewInvertedIndex(),
+		Logger:   nopLogger{},
+	}
+	if err := updater.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if _, ok := updater.Index.Document("page"); ok {
+		t.Fatalf("expected page to be removed from the index")
+	}
+	if _, ok := updater.Semantic.DocumentVector("page"); ok {
+		t.Fatalf("expected page to be removed from the semantic index")
+	}
+	if got := updater.Images.DocumentCount(); got != 0 {
+		t.Fatalf("expected images of the deleted page to be removed, got %d", got)
+	}
+	if _, ok := updater.Index.Document("keep"); !ok {
+		t.Fatalf("expected unrelated document to remain")
+	}
+}
+
+func TestIndexUpdaterSnapshotRecordsPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	consumer := &positionedConsumer{sliceConsumer: sliceConsumer{docs: []*docs.Document{
+		{ID: "a", Content: "kafka consumer groups"},
+		{ID: "b", Content: "raft consensus"},
+	}}}
+	updater := &pipeline.IndexUpdater{
+		Consumer:      consumer,
+		Index:         index.NewShardedIndex(1),
+		SnapshotPath:  path,
+		SnapshotEvery: time.Hour,
+		Logger:        nopLogger{},
+	}
+	if err := updater.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// Only the first document triggers a snapshot, so it must resume at offset 1.
+	loaded, err := index.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	pos, ok, err := loaded.Position()
+	if err != nil || !ok {
+		t.Fatalf("expected a recorded position, got %v, %v", ok, err)
+	}
+	if pos.Topic != "documents" || len(pos.Offsets) != 1 || pos.Offsets[0] != 1 {
+		t.Fatalf("unexpected position %+v", pos)
+	}
+	if len(loaded.Documents) != 1 || loaded.Documents[0].ID != "a" {
+		t.Fatalf("expected the snapshot to hold exactly the documents before its position, got %d", len(loaded.Documents))
+	}
+}
+
+func TestIndexUpdaterSnapshotFailureIsFatal(t *testing.T) {
+	// A file where the snapshot directory should be makes every write fail.
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+	updater := &pipeline.IndexUpdater{
+		Consumer:      &sliceConsumer{docs: []*docs.Document{{ID: "a", Content: "kafka"}}},
+		Index:         index.NewShardedIndex(1),
+		SnapshotPath:  filepath.Join(blocker, "index.snapshot"),
+		SnapshotEvery: time.Hour,
+		Logger:        nopLogger{},
+	}
+	var fatal *pipeline.FatalError
+	if err := updater.Run(context.Background()); !errors.As(err, &fatal) {
+		t.Fatalf("expected a fatal error when the snapshot cannot be written, got %v", err)
+	}
+}
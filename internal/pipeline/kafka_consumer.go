@@ -3,9 +3,12 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
 	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
 	"github.com/segmentio/kafka-go"
 )
@@ -13,16 +16,87 @@ import (
 // DocumentHandler processes a document delivered from the transport layer.
 type DocumentHandler func(*docs.Document) error
 
+// FatalError marks a handler error that no retry or dead letter can fix, such as a
+// snapshot that cannot be written. Consumption stops with it once retries run out.
+type FatalError struct {
+	Err error
+}
+
+func (e *FatalError) Error() string { return e.Err.Error() }
+
+func (e *FatalError) Unwrap() error { return e.Err }
+
 // DocumentConsumer represents a streaming consumer for indexed documents.
 type DocumentConsumer interface {
 	Consume(ctx context.Context, handler DocumentHandler) error
 	Close() error
 }
 
-// KafkaConsumer consumes documents from a Kafka topic.
+// PositionedConsumer is a DocumentConsumer that reports how far into its stream it has
+// read. Snapshots record the position so that loading one and resuming from it neither
+// skips nor repeats a change.
+type PositionedConsumer interface {
+	DocumentConsumer
+	Position() index.StreamPosition
+}
+
+// KafkaConsumer consumes documents from a Kafka topic, either as a member of a consumer
+// group that shares the partitions, or in broadcast mode, reading every partition
+// itself.
 type KafkaConsumer struct {
-	reader *kafka.Reader
+	reader groupReader
 	logger telemetry.Logger
+
+	brokers []string
+	topic   string
+	// StartOffsets seeds broadcast mode with the next offset to read per partition.
+	StartOffsets map[int]int64
+	// StartTime seeds broadcast mode for partitions missing from StartOffsets: reading
+	// starts at the first message written at or after it, or at the beginning of the
+	// partition when it is zero.
+	StartTime time.Time
+	// MaxRetries is how many times a failed handler is retried, waiting RetryBackoff
+	// before the first retry and twice as long before each one after it.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// DeadLetterTopic receives messages that cannot be decoded or whose handler fails
+	// every retry, with the failure described in headers. When it is empty they are
+	// logged and skipped.
+	DeadLetterTopic string
+
+	mu          sync.Mutex
+	readers     []partitionReader
+	offsets     map[int]int64
+	deadLetters messageWriter
+
+	// partitions and openReader reach the brokers in broadcast mode, and wait sleeps
+	// between retries; tests replace them.
+	partitions func(ctx context.Context) ([]int, error)
+	openReader func(partition int) partitionReader
+	wait       func(ctx context.Context, d time.Duration) error
+}
+
+// groupReader is the part of kafka.Reader that consumer-group mode uses.
+type groupReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// messageWriter is the part of kafka.Writer that publishes dead letters.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// partitionReader is the part of kafka.Reader that broadcast mode uses to read one
+// partition.
+type partitionReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	SetOffset(offset int64) error
+	SetOffsetAt(ctx context.Context, t time.Time) error
+	Offset() int64
+	Close() error
 }
 
 // NewKafkaConsumer creates a new Kafka consumer bound to the provided topic and group.
@@ -34,34 +108,308 @@ func NewKafkaConsumer(brokers []string, topic, groupID string, logger telemetry.
 			GroupID:        groupID,
 			CommitInterval: time.Second,
 		}),
-		logger: logger,
+		brokers:      brokers,
+		topic:        topic,
+		logger:       logger,
+		MaxRetries:   3,
+		RetryBackoff: 200 * time.Millisecond,
+		offsets:      make(map[int]int64),
+		wait:         sleep,
 	}
 }
 
-// Consume continuously reads messages and invokes the handler until the context is canceled or an error occurs.
+// NewBroadcastKafkaConsumer creates a consumer that reads every partition of the topic
+// without joining a consumer group, so each replica receives the whole stream. Nothing
+// is committed to Kafka; the consumer tracks its own offsets, reported by Offsets, and
+// starts from StartOffsets or StartTime. Partitions are discovered when Consume starts.
+func NewBroadcastKafkaConsumer(brokers []string, topic string, logger telemetry.Logger) *KafkaConsumer {
+	return &KafkaConsumer{
+		brokers:      brokers,
+		topic:        topic,
+		logger:       logger,
+		MaxRetries:   3,
+		RetryBackoff: 200 * time.Millisecond,
+		offsets:      make(map[int]int64),
+		wait:         sleep,
+		partitions: func(ctx context.Context) ([]int, error) {
+			partitions, err := lookupPartitions(ctx, brokers, topic)
+			ids := make([]int, len(partitions))
+			for i, partition := range partitions {
+				ids[i] = partition.ID
+			}
+			return ids, err
+		},
+		openReader: func(partition int) partitionReader {
+			return kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, Partition: partition})
+		},
+	}
+}
+
+// Consume continuously reads messages and invokes the handler until the context is
+// canceled or an error occurs. A message is committed only once the handler has
+// succeeded or the message has been dead-lettered, so after a crash it is delivered
+// again rather than lost.
 func (k *KafkaConsumer) Consume(ctx context.Context, handler DocumentHandler) error {
+	if k.reader == nil {
+		return k.consumeBroadcast(ctx, handler)
+	}
 	for {
-		m, err := k.reader.ReadMessage(ctx)
+		m, err := k.reader.FetchMessage(ctx)
+		if err == nil {
+			err = k.handle(ctx, m, handler)
+		}
+		if err == nil {
+			err = k.reader.CommitMessages(ctx, m)
+		}
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				return nil
 			}
 			return err
 		}
-		doc, err := UnmarshalDocument(m.Value)
+	}
+}
+
+// consumeBroadcast reads every partition in its own goroutine and hands the messages to
+// the handler one at a time. Order is kept within a partition, and since documents are
+// keyed by ID, every version of a document is applied in order.
+func (k *KafkaConsumer) consumeBroadcast(ctx context.Context, handler DocumentHandler) error {
+	readers, err := k.openPartitions(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages := make(chan kafka.Message)
+	failed := make(chan error, len(readers))
+	for _, reader := range readers {
+		go func() {
+			for {
+				m, err := reader.FetchMessage(ctx)
+				if err != nil {
+					failed <- err
+					return
+				}
+				select {
+				case messages <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	for {
+		select {
+		case m := <-messages:
+			if err := k.handle(ctx, m, handler); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				return err
+			}
+		case err := <-failed:
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// openPartitions creates a reader for every partition of the topic, positioned at the
+// tracked offset when there is one, then at StartOffsets, then at StartTime. The
+// starting offset is tracked at once, so a partition that stays idle keeps its place
+// in Position.
+func (k *KafkaConsumer) openPartitions(ctx context.Context) ([]partitionReader, error) {
+	partitions, err := k.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, reader := range k.readers {
+		reader.Close()
+	}
+	k.readers = nil
+	for _, partition := range partitions {
+		reader := k.openReader(partition)
+		k.readers = append(k.readers, reader)
+		offset, ok := k.offsets[partition]
+		if !ok {
+			offset, ok = k.StartOffsets[partition]
+		}
+		switch {
+		case ok:
+			err = reader.SetOffset(offset)
+		case !k.StartTime.IsZero():
+			err = reader.SetOffsetAt(ctx, k.StartTime)
+		default:
+			err = reader.SetOffset(kafka.FirstOffset)
+		}
 		if err != nil {
-			k.logger.Error("kafka_unmarshal_failed", err)
-			continue
+			return nil, fmt.Errorf("seek partition %d: %w", partition, err)
+		}
+		k.offsets[partition] = reader.Offset()
+		k.logger.Info("kafka_partition_assigned", "topic", k.topic, "partition", partition, "offset", reader.Offset())
+	}
+	return k.readers, nil
+}
+
+// lookupPartitions asks each broker in turn for the topic's partitions.
+func lookupPartitions(ctx context.Context, brokers []string, topic string) ([]kafka.Partition, error) {
+	var errs []error
+	for _, broker := range brokers {
+		partitions, err := kafka.DefaultDialer.LookupPartitions(ctx, "tcp", broker, topic)
+		if err == nil {
+			return partitions, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("lookup partitions of %s: %w", topic, errors.Join(errs...))
+}
+
+// handle decodes one message and passes it to the handler, retrying failures and
+// dead-lettering the message once retries run out. The partition's next offset is
+// recorded first, so a snapshot written by the handler covers the message it has just
+// applied; it is put back when handle fails, and the message must then be read again.
+// Fatal errors are returned rather than dead-lettered.
+func (k *KafkaConsumer) handle(ctx context.Context, m kafka.Message, handler DocumentHandler) error {
+	k.setOffset(m.Partition, m.Offset+1)
+	stage, attempts, err := k.process(ctx, m, handler)
+	if err == nil {
+		return nil
+	}
+	var fatal *FatalError
+	if ctx.Err() == nil && !errors.As(err, &fatal) {
+		err = k.deadLetter(ctx, m, stage, attempts, err)
+	}
+	if err != nil {
+		k.setOffset(m.Partition, m.Offset)
+	}
+	return err
+}
+
+// process decodes and handles one message, reporting the stage that failed and how
+// many times the handler ran.
+func (k *KafkaConsumer) process(ctx context.Context, m kafka.Message, handler DocumentHandler) (string, int, error) {
+	doc, err := decodeMessage(m)
+	if err != nil {
+		return StageDecode, 0, err
+	}
+	backoff := k.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := handler(doc)
+		if err == nil {
+			return "", attempt, nil
+		}
+		if attempt > k.MaxRetries {
+			return StageHandler, attempt, err
+		}
+		telemetry.IncKafkaHandlerRetries()
+		k.logger.Error("document_handler_retry", err, "doc_id", doc.ID, "attempt", attempt, "backoff", backoff.String())
+		if err := k.wait(ctx, backoff); err != nil {
+			return StageHandler, attempt, err
+		}
+		backoff *= 2
+	}
+}
+
+// deadLetter publishes a message that failed for good to DeadLetterTopic, or logs and
+// skips it when there is none. It fails only when publishing does, leaving the message
+// unprocessed.
+func (k *KafkaConsumer) deadLetter(ctx context.Context, m kafka.Message, stage string, attempts int, cause error) error {
+	if k.DeadLetterTopic == "" {
+		k.logger.Error("kafka_message_dropped", cause, "stage", stage, "partition", m.Partition, "offset", m.Offset, "key", string(m.Key))
+		telemetry.IncKafkaDeadLetters(stage)
+		return nil
+	}
+	if k.deadLetters == nil {
+		k.deadLetters = &kafka.Writer{
+			Addr:         kafka.TCP(k.brokers...),
+			Topic:        k.DeadLetterTopic,
+			RequiredAcks: kafka.RequireAll,
 		}
-		if err := handler(doc); err != nil {
-			k.logger.Error("document_handler_failed", err, "doc_id", doc.ID)
+	}
+	if err := k.deadLetters.WriteMessages(ctx, DeadLetterMessage(m, stage, attempts, cause)); err != nil {
+		return fmt.Errorf("dead-letter message at partition %d offset %d: %w", m.Partition, m.Offset, err)
+	}
+	k.logger.Error("kafka_message_dead_lettered", cause, "stage", stage, "partition", m.Partition, "offset", m.Offset, "key", string(m.Key), "topic", k.DeadLetterTopic)
+	telemetry.IncKafkaDeadLetters(stage)
+	return nil
+}
+
+// sleep waits for d or until ctx ends.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (k *KafkaConsumer) setOffset(partition int, offset int64) {
+	k.mu.Lock()
+	k.offsets[partition] = offset
+	k.mu.Unlock()
+}
+
+// Offsets returns the next offset to read for every partition the consumer has read
+// from.
+func (k *KafkaConsumer) Offsets() map[int]int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	offsets := make(map[int]int64, len(k.offsets))
+	for partition, offset := range k.offsets {
+		offsets[partition] = offset
+	}
+	return offsets
+}
+
+// decodeMessage turns a Kafka message into the document its event applies. An empty
+// value is a tombstone for the document whose ID is the message key, as published before
+// deletes were events.
+func decodeMessage(m kafka.Message) (*docs.Document, error) {
+	if len(m.Value) == 0 {
+		if len(m.Key) == 0 {
+			return nil, errors.New("tombstone without document key")
 		}
+		return docs.Tombstone(string(m.Key)), nil
+	}
+	event, err := UnmarshalEvent(m.Value)
+	if err != nil {
+		return nil, err
 	}
+	return event.Doc()
 }
 
-// Close closes the reader.
+// Position returns the topic and the offsets reported by Offsets.
+func (k *KafkaConsumer) Position() index.StreamPosition {
+	return index.StreamPosition{Topic: k.topic, Offsets: k.Offsets()}
+}
+
+// Close closes the readers and the dead-letter writer.
 func (k *KafkaConsumer) Close() error {
-	return k.reader.Close()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var errs []error
+	if k.reader != nil {
+		errs = append(errs, k.reader.Close())
+	}
+	for _, reader := range k.readers {
+		errs = append(errs, reader.Close())
+	}
+	if k.deadLetters != nil {
+		errs = append(errs, k.deadLetters.Close())
+	}
+	return errors.Join(errs...)
 }
 
-var _ DocumentConsumer = (*KafkaConsumer)(nil)
+var _ PositionedConsumer = (*KafkaConsumer)(nil)
@@ -0,0 +1,315 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/segmentio/kafka-go"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+
+// fakePartition serves a fixed list of messages from its offset onwards, then blocks
+// until the context ends.
+type fakePartition struct {
+	mu       sync.Mutex
+	offset   int64
+	messages []kafka.Message
+}
+
+func (p *fakePartition) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	p.mu.Lock()
+	for _, m := range p.messages {
+		if m.Offset >= p.offset {
+			p.offset = m.Offset + 1
+			p.mu.Unlock()
+			return m, nil
+		}
+	}
+	p.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (p *fakePartition) SetOffset(offset int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offset = offset
+	if offset == kafka.FirstOffset {
+		p.offset = 0
+	}
+	return nil
+}
+
+func (p *fakePartition) SetOffsetAt(context.Context, time.Time) error { return nil }
+
+func (p *fakePartition) Offset() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.offset
+}
+
+func (p *fakePartition) Close() error { return nil }
+
+// fakeTopic makes a broadcast consumer read the given partitions instead of Kafka.
+func fakeTopic(partitions map[int][]kafka.Message, startOffsets map[int]int64) *KafkaConsumer {
+	k := NewBroadcastKafkaConsumer(nil, "documents", nopLogger{})
+	k.StartOffsets = startOffsets
+	k.partitions = func(context.Context) ([]int, error) {
+		ids := make([]int, 0, len(partitions))
+		for id := range partitions {
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+	k.openReader = func(partition int) partitionReader {
+		return &fakePartition{messages: partitions[partition]}
+	}
+	return k
+}
+
+func documentMessage(t *testing.T, partition int, offset int64, id string) kafka.Message {
+	t.Helper()
+	payload, err := MarshalEvent(NewEvent(&docs.Document{ID: id, Content: "kafka " + id}, "test"), CodecJSON)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return kafka.Message{Topic: "documents", Partition: partition, Offset: offset, Key: []byte(id), Value: payload}
+}
+
+// consumeUntil runs the consumer until it has handled n documents.
+func consumeUntil(t *testing.T, k *KafkaConsumer, n int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handled := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- k.Consume(ctx, func(*docs.Document) error {
+			if handled++; handled == n {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("consume: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out after %d of %d documents", handled, n)
+	}
+}
+
+func TestBroadcastPositionSurvivesRestartWithIdlePartition(t *testing.T) {
+	partitions := map[int][]kafka.Message{
+		0: {documentMessage(t, 0, 0, "a"), documentMessage(t, 0, 1, "b")},
+		1: {documentMessage(t, 1, 0, "c"), documentMessage(t, 1, 1, "d"), documentMessage(t, 1, 2, "e")},
+	}
+
+	// The first run read partition 1 up to offset 3; partition 0 gets two new messages.
+	first := fakeTopic(partitions, map[int]int64{1: 3})
+	consumeUntil(t, first, 2)
+	pos := first.Position()
+	if pos.Offsets[0] != 2 || pos.Offsets[1] != 3 {
+		t.Fatalf("expected the idle partition to keep its start offset, got %v", pos.Offsets)
+	}
+
+	// After a restart from that position, nothing is read again and partition 1 is still
+	// tracked before anything arrives on it.
+	restarted := fakeTopic(partitions, pos.Offsets)
+	if _, err := restarted.openPartitions(context.Background()); err != nil {
+		t.Fatalf("open partitions: %v", err)
+	}
+	if got := restarted.Position().Offsets; got[0] != 2 || got[1] != 3 {
+		t.Fatalf("expected the restored position %v, got %v", pos.Offsets, got)
+	}
+}
+
+// fakeWriter records dead letters, failing every write when err is set.
+type fakeWriter struct {
+	err      error
+	messages []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+// retryingConsumer returns a consumer whose retries record their backoff instead of
+// sleeping.
+func retryingConsumer(writer *fakeWriter) (*KafkaConsumer, *[]time.Duration) {
+	k := NewBroadcastKafkaConsumer(nil, "documents", nopLogger{})
+	k.MaxRetries = 3
+	k.RetryBackoff = 10 * time.Millisecond
+	k.DeadLetterTopic = "documents.dlq"
+	k.deadLetters = writer
+	var waits []time.Duration
+	k.wait = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return k, &waits
+}
+
+// failing returns a handler that fails its first n calls with err.
+func failing(n int, err error) (DocumentHandler, *int) {
+	calls := 0
+	return func(*docs.Document) error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestHandleRetriesWithExponentialBackoff(t *testing.T) {
+	writer := &fakeWriter{}
+	k, waits := retryingConsumer(writer)
+	handler, calls := failing(2, errors.New("busy"))
+
+	if err := k.handle(context.Background(), documentMessage(t, 0, 7, "a"), handler); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if *calls != 3 || len(writer.messages) != 0 {
+		t.Fatalf("expected success on the third call without dead letters, got %d calls and %d dead letters", *calls, len(writer.messages))
+	}
+	if want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}; !slices.Equal(*waits, want) {
+		t.Fatalf("expected backoff %v, got %v", want, *waits)
+	}
+	if got := k.Offsets()[0]; got != 8 {
+		t.Fatalf("expected offset 8 after success, got %d", got)
+	}
+}
+
+func TestHandleDeadLettersAfterRetries(t *testing.T) {
+	writer := &fakeWriter{}
+	k, waits := retryingConsumer(writer)
+	handler, calls := failing(100, errors.New("index unavailable"))
+
+	if err := k.handle(context.Background(), documentMessage(t, 0, 7, "a"), handler); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if *calls != 4 || len(*waits) != 3 {
+		t.Fatalf("expected 4 calls and 3 waits, got %d and %d", *calls, len(*waits))
+	}
+	if len(writer.messages) != 1 {
+		t.Fatalf("expected one dead letter, got %d", len(writer.messages))
+	}
+	dead := writer.messages[0]
+	if header(dead, HeaderDeadLetterStage) != StageHandler || header(dead, HeaderDeadLetterAttempts) != "4" ||
+		header(dead, HeaderDeadLetterError) != "index unavailable" || header(dead, HeaderDeadLetterOffset) != "7" {
+		t.Fatalf("unexpected dead-letter headers %+v", dead.Headers)
+	}
+	if got := k.Offsets()[0]; got != 8 {
+		t.Fatalf("expected a dead-lettered message to count as processed, got offset %d", got)
+	}
+
+	// Undecodable messages are dead-lettered without reaching the handler.
+	poison := kafka.Message{Partition: 0, Offset: 8, Key: []byte("b"), Value: []byte("{")}
+	*calls = 0
+	if err := k.handle(context.Background(), poison, handler); err != nil || *calls != 0 {
+		t.Fatalf("expected the poison message to be dead-lettered unhandled, got %v after %d calls", err, *calls)
+	}
+	if len(writer.messages) != 2 || header(writer.messages[1], HeaderDeadLetterStage) != StageDecode {
+		t.Fatalf("expected a decode dead letter, got %d messages", len(writer.messages))
+	}
+}
+
+func TestHandleRestoresOffsetWhenDeadLetteringFails(t *testing.T) {
+	writer := &fakeWriter{err: errors.New("broker down")}
+	k, _ := retryingConsumer(writer)
+	handler, _ := failing(100, errors.New("index unavailable"))
+
+	if err := k.handle(context.Background(), documentMessage(t, 0, 7, "a"), handler); err == nil {
+		t.Fatalf("expected handle to fail when the dead letter cannot be written")
+	}
+	if got := k.Offsets()[0]; got != 7 {
+		t.Fatalf("expected the offset to stay at the failed message, got %d", got)
+	}
+
+	// Fatal errors stop consumption without a dead letter.
+	writer.err = nil
+	fatal, _ := failing(100, &FatalError{Err: errors.New("disk full")})
+	if err := k.handle(context.Background(), documentMessage(t, 0, 7, "a"), fatal); err == nil || len(writer.messages) != 0 {
+		t.Fatalf("expected a fatal error without dead letters, got %v and %d messages", err, len(writer.messages))
+	}
+}
+
+// fakeGroup serves messages once and records commits.
+type fakeGroup struct {
+	messages  []kafka.Message
+	committed []int64
+}
+
+func (g *fakeGroup) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(g.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	m := g.messages[0]
+	g.messages = g.messages[1:]
+	return m, nil
+}
+
+func (g *fakeGroup) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		g.committed = append(g.committed, m.Offset)
+	}
+	return nil
+}
+
+func (g *fakeGroup) Close() error { return nil }
+
+func TestGroupConsumerCommitsOnlyProcessedMessages(t *testing.T) {
+	writer := &fakeWriter{}
+	k, _ := retryingConsumer(writer)
+	group := &fakeGroup{messages: []kafka.Message{
+		documentMessage(t, 0, 0, "ok"),
+		documentMessage(t, 0, 1, "bad"),
+		documentMessage(t, 0, 2, "stuck"),
+	}}
+	k.reader = group
+	handler := func(doc *docs.Document) error {
+		switch doc.ID {
+		case "bad":
+			return errors.New("rejected")
+		case "stuck":
+			writer.err = errors.New("broker down")
+			return errors.New("rejected")
+		}
+		return nil
+	}
+
+	if err := k.Consume(context.Background(), handler); err == nil {
+		t.Fatalf("expected consumption to stop when a dead letter cannot be written")
+	}
+	if !slices.Equal(group.committed, []int64{0, 1}) {
+		t.Fatalf("expected commits for the handled and dead-lettered messages only, got %v", group.committed)
+	}
+}
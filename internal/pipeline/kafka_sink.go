@@ -10,8 +10,15 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-// KafkaSink implements crawler.DocumentSink by publishing JSON documents to Kafka.
+// sourceHeader names the producer of a message.
+const sourceHeader = "source"
+
+// KafkaSink implements crawler.DocumentSink by publishing document events to Kafka.
 type KafkaSink struct {
+	// Source names the producer in every event.
+	Source string
+	// Codec is CodecJSON (the default when empty) or CodecBinary.
+	Codec  string
 	writer *kafka.Writer
 	logger telemetry.Logger
 }
@@ -29,19 +36,38 @@ func NewKafkaSink(brokers []string, topic string, logger telemetry.Logger) *Kafk
 	}
 }
 
-// Consume publishes the document to Kafka.
+// Consume publishes the document as an event keyed by its ID, so every version of a
+// document lands on the same partition. Deleted documents are published as tombstones
+// with a nil value, which log compaction eventually removes along with the key.
 func (k *KafkaSink) Consume(doc *docs.Document) {
-	payload, err := MarshalDocument(doc)
+	msg, err := k.message(doc)
 	if err != nil {
 		k.logger.Error("marshal_document_failed", err, "doc_id", doc.ID)
 		return
 	}
-	msg := kafka.Message{Value: payload}
 	if err := k.writer.WriteMessages(context.Background(), msg); err != nil {
 		k.logger.Error("kafka_write_failed", err, "doc_id", doc.ID)
 	}
 }
 
+// message builds the Kafka message for a document. The producer travels in a header,
+// which tombstones can carry without a value.
+func (k *KafkaSink) message(doc *docs.Document) (kafka.Message, error) {
+	msg := kafka.Message{Key: []byte(doc.ID)}
+	if k.Source != "" {
+		msg.Headers = []kafka.Header{{Key: sourceHeader, Value: []byte(k.Source)}}
+	}
+	if doc.Deleted {
+		return msg, nil
+	}
+	payload, err := MarshalEvent(NewEvent(doc, k.Source), k.Codec)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	msg.Value = payload
+	return msg, nil
+}
+
 // Close flushes and closes the underlying writer.
 func (k *KafkaSink) Close() {
 	if err := k.writer.Close(); err != nil {
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+)
+
+func TestKafkaSinkPublishesDeletesAsTombstones(t *testing.T) {
+	for _, codec := range []string{CodecJSON, CodecBinary} {
+		sink := NewKafkaSink(nil, "documents", nopLogger{})
+		sink.Source, sink.Codec = "crawler", codec
+
+		msg, err := sink.message(docs.Tombstone("gone"))
+		if err != nil {
+			t.Fatalf("%s: tombstone message: %v", codec, err)
+		}
+		if msg.Value != nil || string(msg.Key) != "gone" {
+			t.Fatalf("%s: expected a nil-value tombstone keyed by ID, got key %q value %q", codec, msg.Key, msg.Value)
+		}
+		if len(msg.Headers) != 1 || msg.Headers[0].Key != sourceHeader || string(msg.Headers[0].Value) != "crawler" {
+			t.Fatalf("%s: expected the source in a header, got %+v", codec, msg.Headers)
+		}
+		if doc, err := decodeMessage(msg); err != nil || !doc.Deleted || doc.ID != "gone" {
+			t.Fatalf("%s: expected the tombstone to decode as a delete, got %+v, %v", codec, doc, err)
+		}
+
+		msg, err = sink.message(&docs.Document{ID: "page", Title: "Kafka"})
+		if err != nil || msg.Value == nil {
+			t.Fatalf("%s: expected an upsert event, got %+v, %v", codec, msg, err)
+		}
+		if doc, err := decodeMessage(msg); err != nil || doc.Deleted || doc.Title != "Kafka" {
+			t.Fatalf("%s: expected the upsert to decode, got %+v, %v", codec, doc, err)
+		}
+	}
+}
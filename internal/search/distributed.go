@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// ShardClient reaches the shards held by one search node of a cluster.
+type ShardClient interface {
+	// Prepare returns the node's collection statistics and multi-term matches for a
+	// query, the distributed-frequency phase that makes scores comparable across nodes.
+	Prepare(ctx context.Context, req PrepareRequest) (PrepareResponse, error)
+	// Search scores the node's documents with cluster-wide statistics and returns its
+	// top results.
+	Search(ctx context.Context, req ShardSearchRequest) ([]Result, error)
+}
+
+// PrepareRequest asks a node for the statistics of a query's terms.
+type PrepareRequest struct {
+	Terms      []string    `json:"terms"`
+	MultiTerms []MultiTerm `json:"multi_terms,omitempty"`
+	// Limit bounds the matches returned per multi-term.
+	Limit int `json:"limit,omitempty"`
+}
+
+// PrepareResponse holds a node's statistics for the requested terms and for every
+// term it matched, with Matches in the order of the request's MultiTerms.
+type PrepareResponse struct {
+	Stats   index.Stats   `json:"stats"`
+	Matches [][]TermMatch `json:"matches,omitempty"`
+}
+
+// ShardSearchRequest is a parsed and expanded query with the statistics of the whole
+// cluster.
+type ShardSearchRequest struct {
+	Query  string      `json:"query"`
+	Parsed Query       `json:"parsed"`
+	Stats  index.Stats `json:"stats"`
+	TopK   int         `json:"top_k"`
+}
+
+// PrepareShards answers the distributed-frequency phase from the local shards.
+func (s *Service) PrepareShards(req PrepareRequest) PrepareResponse {
+	readers := s.readers()
+	resp := PrepareResponse{Matches: make([][]TermMatch, len(req.MultiTerms))}
+	terms := append([]string(nil), req.Terms...)
+	for i, m := range req.MultiTerms {
+		resp.Matches[i] = selectMatches(collectMatches(readers, m), req.Limit)
+		for _, match := range resp.Matches[i] {
+			terms = append(terms, match.Term)
+		}
+	}
+	resp.Stats = mergedStats(readers, terms)
+	return resp
+}
+
+// SearchShards searches the local shards for one node's part of a cluster query.
+func (s *Service) SearchShards(req ShardSearchRequest) []Result {
+	readers := s.readers()
+	if len(readers) == 0 {
+		return nil
+	}
+	return s.searchReaders(readers, req.Query, req.Parsed, req.Stats, req.TopK)
+}
+
+// searchNodes coordinates a cluster query: it gathers statistics and multi-term
+// matches from every node, sends each node the query with the combined statistics,
+// and merges their top results. Nodes that fail either phase are left out and the
+// results are reported partial.
+func (s *Service) searchNodes(query string, topK int) ([]Result, bool) {
+	parsed, ok := s.parse(query)
+	if !ok {
+		return nil, false
+	}
+
+	prepareReq := PrepareRequest{Terms: parsed.AllTerms(), MultiTerms: parsed.MultiTerms, Limit: s.MaxExpansions}
+	prepared, errs := callNodes(s.Nodes, s.NodeTimeout, func(ctx context.Context, node ShardClient) (PrepareResponse, error) {
+		return node.Prepare(ctx, prepareReq)
+	})
+	var stats index.Stats
+	found := make([]map[string]TermMatch, len(parsed.MultiTerms))
+	for i := range found {
+		found[i] = make(map[string]TermMatch)
+	}
+	var live []ShardClient
+	for i, resp := range prepared {
+		if errs[i] != nil || len(resp.Matches) != len(parsed.MultiTerms) {
+			telemetry.IncSearchNodeFailures("prepare")
+			continue
+		}
+		live = append(live, s.Nodes[i])
+		stats.Merge(resp.Stats)
+		for j, matches := range resp.Matches {
+			for _, match := range matches {
+				addMatch(found[j], match)
+			}
+		}
+	}
+	partial := len(live) < len(s.Nodes)
+	if len(live) == 0 {
+		return nil, partial
+	}
+	for i, m := range parsed.MultiTerms {
+		parsed.MultiTerms[i] = m.withMatches(selectMatches(found[i], s.MaxExpansions))
+	}
+
+	searchReq := ShardSearchRequest{Query: query, Parsed: parsed, Stats: stats, TopK: topK}
+	perNode, errs := callNodes(live, s.NodeTimeout, func(ctx context.Context, node ShardClient) ([]Result, error) {
+		return node.Search(ctx, searchReq)
+	})
+	var results []Result
+	for i, nodeResults := range perNode {
+		if errs[i] != nil {
+			telemetry.IncSearchNodeFailures("search")
+			partial = true
+			continue
+		}
+		results = append(results, nodeResults...)
+	}
+	sortResults(results)
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results, partial
+}
+
+type nodeReply[T any] struct {
+	node  int
+	value T
+	err   error
+}
+
+// callNodes calls every node in parallel and waits at most timeout for them. Nodes
+// that have not answered by then report context.DeadlineExceeded; their calls are
+// abandoned rather than awaited.
+func callNodes[T any](nodes []ShardClient, timeout time.Duration, call func(context.Context, ShardClient) (T, error)) ([]T, []error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	replies := make(chan nodeReply[T], len(nodes))
+	for i, node := range nodes {
+		go func() {
+			value, err := call(ctx, node)
+			replies <- nodeReply[T]{node: i, value: value, err: err}
+		}()
+	}
+
+	values := make([]T, len(nodes))
+	errs := make([]error, len(nodes))
+	for i := range errs {
+		errs[i] = context.DeadlineExceeded
+	}
+	for range nodes {
+		select {
+		case reply := <-replies:
+			values[reply.node], errs[reply.node] = reply.value, reply.err
+		case <-ctx.Done():
+			return values, errs
+		}
+	}
+	return values, errs
+}
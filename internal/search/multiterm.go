@@ -0,0 +1,260 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+)
+
+// MultiTermKind selects how a MultiTerm pattern is matched against indexed terms.
+type MultiTermKind int
+
+const (
+	// MatchPrefix matches terms starting with the pattern, written distrib*.
+	MatchPrefix MultiTermKind = iota + 1
+	// MatchWildcard matches terms against a pattern where ? is any one character and *
+	// any run of characters, written k?fka or dist*ed. Question marks ending a word that
+	// has no other wildcard are punctuation, so "does it work?" searches for work.
+	MatchWildcard
+	// MatchFuzzy matches terms within Edits insertions, deletions, substitutions, or
+	// transpositions of the pattern, written kafak~1.
+	MatchFuzzy
+)
+
+// MaxFuzzyEdits is the largest edit distance a fuzzy term may ask for.
+const MaxFuzzyEdits = 2
+
+// MultiTerm is a query term that matches several indexed terms. The pattern is case
+// and accent folded but not otherwise analyzed, so with a stemming analyzer it matches
+// stems. Terms holds the expansion once the query has been run against an index.
+type MultiTerm struct {
+	Kind    MultiTermKind `json:"kind"`
+	Pattern string        `json:"pattern"`
+	Edits   int           `json:"edits,omitempty"`
+	Field   string        `json:"field,omitempty"`
+	Terms   []Term        `json:"terms,omitempty"`
+}
+
+// parseMultiTerm recognizes prefix, wildcard, and fuzzy syntax in one query word.
+func parseMultiTerm(word, field string) (MultiTerm, bool) {
+	if strings.ContainsAny(strings.TrimRight(word, "?"), "*?") {
+		pattern := foldText(word)
+		if strings.Trim(pattern, "*?") == "" {
+			return MultiTerm{}, false
+		}
+		kind := MatchWildcard
+		if strings.IndexAny(pattern, "*?") == len(pattern)-1 && strings.HasSuffix(pattern, "*") {
+			kind, pattern = MatchPrefix, strings.TrimSuffix(pattern, "*")
+		}
+		return MultiTerm{Kind: kind, Pattern: pattern, Field: field}, true
+	}
+	tilde := strings.LastIndexByte(word, '~')
+	if tilde <= 0 {
+		return MultiTerm{}, false
+	}
+	edits := MaxFuzzyEdits
+	if digits := word[tilde+1:]; digits != "" {
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return MultiTerm{}, false
+		}
+		edits = min(n, MaxFuzzyEdits)
+	}
+	return MultiTerm{Kind: MatchFuzzy, Pattern: foldText(word[:tilde]), Edits: edits, Field: field}, true
+}
+
+// TermMatch is an indexed term matched by a multi-term, with what ranks and weights it.
+type TermMatch struct {
+	Term     string  `json:"term"`
+	DocFreq  int     `json:"doc_freq"`
+	Weight   float64 `json:"weight"`
+	Distance int     `json:"distance,omitempty"`
+}
+
+// expandMultiTerms fills in the Terms of each multi-term from the readers' term
+// dictionaries, keeping at most limit terms per pattern. Prefix and wildcard expansions
+// keep the most frequent terms and score them like the typed term; fuzzy expansions
+// keep the closest terms and score each edit lower.
+func expandMultiTerms(readers []*index.Reader, multi []MultiTerm, limit int) []MultiTerm {
+	if len(multi) == 0 {
+		return nil
+	}
+	out := make([]MultiTerm, len(multi))
+	for i, m := range multi {
+		out[i] = m.withMatches(selectMatches(collectMatches(readers, m), limit))
+	}
+	return out
+}
+
+// collectMatches returns the terms of every reader matching m, keyed by term with
+// their document frequencies added up. Shards are matched separately, which avoids
+// merging whole term dictionaries for every query.
+func collectMatches(readers []*index.Reader, m MultiTerm) map[string]TermMatch {
+	found := make(map[string]TermMatch)
+	for _, reader := range readers {
+		for _, match := range matchMultiTerm(reader.Terms(), m) {
+			if m.Field != "" && reader.FieldDocumentFrequency(m.Field, match.Term) == 0 {
+				continue
+			}
+			addMatch(found, match)
+		}
+	}
+	return found
+}
+
+func addMatch(found map[string]TermMatch, match TermMatch) {
+	if prev, ok := found[match.Term]; ok {
+		match.DocFreq += prev.DocFreq
+	}
+	found[match.Term] = match
+}
+
+// selectMatches ranks matches closest first, then most frequent, and keeps at most
+// limit of them.
+func selectMatches(found map[string]TermMatch, limit int) []TermMatch {
+	matches := make([]TermMatch, 0, len(found))
+	for _, match := range found {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		ma, mb := matches[a], matches[b]
+		if ma.Distance != mb.Distance {
+			return ma.Distance < mb.Distance
+		}
+		if ma.DocFreq != mb.DocFreq {
+			return ma.DocFreq > mb.DocFreq
+		}
+		return ma.Term < mb.Term
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// withMatches returns m expanded to the matched terms.
+func (m MultiTerm) withMatches(matches []TermMatch) MultiTerm {
+	m.Terms = make([]Term, len(matches))
+	for i, match := range matches {
+		m.Terms[i] = Term{Text: match.Term, Field: m.Field, Weight: match.Weight}
+	}
+	return m
+}
+
+// matchMultiTerm returns the terms of a sorted dictionary that match m.
+func matchMultiTerm(dictionary []index.TermFrequency, m MultiTerm) []TermMatch {
+	var matches []TermMatch
+	switch m.Kind {
+	case MatchPrefix:
+		for _, tf := range prefixRange(dictionary, m.Pattern) {
+			matches = append(matches, TermMatch{Term: tf.Term, DocFreq: tf.DocFreq, Weight: 1})
+		}
+	case MatchWildcard:
+		literal := m.Pattern[:strings.IndexAny(m.Pattern, "*?")]
+		for _, tf := range prefixRange(dictionary, literal) {
+			if matchWildcard(m.Pattern, tf.Term) {
+				matches = append(matches, TermMatch{Term: tf.Term, DocFreq: tf.DocFreq, Weight: 1})
+			}
+		}
+	case MatchFuzzy:
+		length := len([]rune(m.Pattern))
+		for _, tf := range dictionary {
+			n := len([]rune(tf.Term))
+			if n < length-m.Edits || n > length+m.Edits {
+				continue
+			}
+			if d := editDistance(m.Pattern, tf.Term); d <= m.Edits {
+				weight := 1 - float64(d)/float64(max(1, min(n, length)))
+				matches = append(matches, TermMatch{Term: tf.Term, DocFreq: tf.DocFreq, Weight: max(weight, 0.1), Distance: d})
+			}
+		}
+	}
+	return matches
+}
+
+// prefixRange returns the terms of a sorted dictionary that start with prefix.
+func prefixRange(dictionary []index.TermFrequency, prefix string) []index.TermFrequency {
+	lo := sort.Search(len(dictionary), func(i int) bool { return dictionary[i].Term >= prefix })
+	hi := lo + sort.Search(len(dictionary)-lo, func(i int) bool {
+		return !strings.HasPrefix(dictionary[lo+i].Term, prefix)
+	})
+	return dictionary[lo:hi]
+}
+
+// matchWildcard reports whether s matches pattern, where ? matches one rune and *
+// matches any number of runes.
+func matchWildcard(pattern, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	pi, ti := 0, 0
+	star, mark := -1, 0
+	for ti < len(t) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == t[ti]):
+			pi++
+			ti++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ti
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			ti = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// editDistance is the Levenshtein distance in runes, counting a swap of adjacent runes
+// as one edit so that "kafak" is one edit from "kafka".
+func editDistance(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	rows := [3][]int{make([]int, len(rb)+1), make([]int, len(rb)+1), make([]int, len(rb)+1)}
+	for j := range rows[1] {
+		rows[1][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		before, prev, curr := rows[0], rows[1], rows[2]
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], before[j-2]+1)
+			}
+		}
+		rows[0], rows[1], rows[2] = prev, curr, before
+	}
+	return rows[1][len(rb)]
+}
+
+// multiTermScores scores each multi-term as one term: a document takes the best score
+// among the expanded terms it contains, so a pattern matching many terms does not
+// outweigh a plain term.
+func (s *Service) multiTermScores(reader *index.Reader, stats collectionStats, multi []MultiTerm) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, m := range multi {
+		best := make(map[string]float64)
+		for _, term := range m.Terms {
+			for docID, score := range s.bm25f(reader, stats, []Term{term}) {
+				best[docID] = max(best[docID], score)
+			}
+		}
+		for docID, score := range best {
+			scores[docID] += score
+		}
+	}
+	return scores
+}
@@ -0,0 +1,97 @@
+package search_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+)
+
+func TestParseQueryMultiTerms(t *testing.T) {
+	q := search.ParseQuery("Distrib* title:k?fka kafak~1 raft~ *", []string{index.FieldTitle}, analysis.Standard())
+	want := []search.MultiTerm{
+		{Kind: search.MatchPrefix, Pattern: "distrib"},
+		{Kind: search.MatchWildcard, Pattern: "k?fka", Field: index.FieldTitle},
+		{Kind: search.MatchFuzzy, Pattern: "kafak", Edits: 1},
+		{Kind: search.MatchFuzzy, Pattern: "raft", Edits: search.MaxFuzzyEdits},
+	}
+	if len(q.MultiTerms) != len(want) || len(q.Terms) != 0 {
+		t.Fatalf("expected %+v and no plain terms, got %+v", want, q)
+	}
+	for i := range want {
+		got := q.MultiTerms[i]
+		if got.Kind != want[i].Kind || got.Pattern != want[i].Pattern || got.Edits != want[i].Edits || got.Field != want[i].Field {
+			t.Fatalf("expected %+v, got %+v", want[i], got)
+		}
+	}
+	if q := search.ParseQuery("a~b", nil, analysis.Standard()); len(q.MultiTerms) != 0 || len(q.Terms) != 2 {
+		t.Fatalf("expected a tilde without a distance to be plain text, got %+v", q)
+	}
+	if q := search.ParseQuery("does it work??", nil, analysis.Standard()); len(q.MultiTerms) != 0 || len(q.Terms) != 3 || q.Terms[2].Text != "work" {
+		t.Fatalf("expected trailing question marks to be punctuation, got %+v", q)
+	}
+	if q := search.ParseQuery("k?fka? wor*?", nil, analysis.Standard()); len(q.MultiTerms) != 2 || q.MultiTerms[0].Pattern != "k?fka?" || q.MultiTerms[1].Pattern != "wor*?" {
+		t.Fatalf("expected a trailing question mark to stay a wildcard next to other wildcards, got %+v", q)
+	}
+}
+
+func TestSearchExpandsMultiTerms(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "dist", Title: "Distributed logs", Content: "Distributed systems distribute work."})
+	idx.AddDocument(&docs.Document{ID: "kafka", Title: "Kafka", Content: "Kafka stores events."})
+	idx.AddDocument(&docs.Document{ID: "kafta", Title: "Kafta", Content: "Kafta is a dish."})
+	idx.AddDocument(&docs.Document{ID: "raft", Title: "Raft", Content: "Raft elects leaders."})
+	svc := search.NewService(idx, nil)
+
+	if results := svc.Search("distrib*", 5); len(results) != 1 || results[0].DocID != "dist" {
+		t.Fatalf("expected prefix match, got %+v", results)
+	}
+	if results := svc.Search("k?f*a", 5); len(results) != 2 {
+		t.Fatalf("expected wildcard to match kafka and kafta, got %+v", results)
+	}
+	results := svc.Search("kafak~1", 5)
+	if len(results) != 1 || results[0].DocID != "kafka" {
+		t.Fatalf("expected a transposition to count as one edit, got %+v", results)
+	}
+	exact := svc.Search("kafka", 5)
+	if results[0].Score >= exact[0].Score {
+		t.Fatalf("expected a fuzzy match to score below an exact match: %v >= %v", results[0].Score, exact[0].Score)
+	}
+	if results := svc.Search("kafak~2", 5); len(results) != 2 || results[0].DocID != "kafka" {
+		t.Fatalf("expected the closer term to rank first, got %+v", results)
+	}
+	if results := svc.Search("work?", 5); len(results) != 1 || results[0].DocID != "dist" {
+		t.Fatalf("expected a question to search its last word, got %+v", results)
+	}
+	if results := svc.Search("title:distrib*", 5); len(results) != 1 {
+		t.Fatalf("expected field restricted prefix match, got %+v", results)
+	}
+	if results := svc.Search("foo:logs*", 5); len(results) != 0 {
+		t.Fatalf("expected an unknown field to stay part of the pattern, got %+v", results)
+	}
+}
+
+func TestMultiTermExpansionIsBounded(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	for i := 0; i < 20; i++ {
+		idx.AddDocument(&docs.Document{ID: fmt.Sprintf("doc-%d", i), Content: fmt.Sprintf("term%02d", i)})
+	}
+	idx.AddDocument(&docs.Document{ID: "popular", Content: "term19"})
+	svc := search.NewService(idx, nil)
+	svc.MaxExpansions = 3
+
+	results := svc.Search("term*", 10)
+	if len(results) != 4 {
+		t.Fatalf("expected three expanded terms to match four documents, got %+v", results)
+	}
+	found := false
+	for _, r := range results {
+		found = found || r.DocID == "popular"
+	}
+	if !found {
+		t.Fatalf("expected the most frequent term to be kept, got %+v", results)
+	}
+}
@@ -0,0 +1,218 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+)
+
+// Query is a parsed search request. Quoted phrases must match; free terms only add score.
+// Expansions are optional phrases, such as multi-word synonyms, that add score when
+// they match but never restrict the results. MultiTerms are prefix, wildcard, and fuzzy
+// terms, which add score like free terms.
+type Query struct {
+	Terms      []Term      `json:"terms,omitempty"`
+	Phrases    []Phrase    `json:"phrases,omitempty"`
+	Expansions []Phrase    `json:"expansions,omitempty"`
+	MultiTerms []MultiTerm `json:"multi_terms,omitempty"`
+}
+
+// Term is a single query token. Field restricts matching to one indexed field; an empty
+// Field matches every field. Weight scales the term's score; zero means 1.
+type Term struct {
+	Text   string  `json:"text"`
+	Field  string  `json:"field,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Phrase is a sequence of terms that must appear in order. Slop allows up to that many
+// extra tokens between the terms, so "vector search"~2 also matches "vector based search".
+// Weight scales the phrase's score; zero means 1.
+type Phrase struct {
+	Terms []string `json:"terms"`
+	// Positions holds the position of each term relative to the first, counting the
+	// stopwords between them, or nil when the terms are adjacent.
+	Positions []int   `json:"positions,omitempty"`
+	Slop      int     `json:"slop,omitempty"`
+	Field     string  `json:"field,omitempty"`
+	Weight    float64 `json:"weight,omitempty"`
+}
+
+func scoreWeight(weight float64) float64 {
+	if weight == 0 {
+		return 1
+	}
+	return weight
+}
+
+// ParseQuery splits raw query text into free terms and quoted phrases with optional
+// "~N" slop. Terms and phrases prefixed with one of fields and a colon, such as
+// title:kafka or title:"event streaming", match only that field. Words such as
+// distrib*, k?fka, and kafak~1 become prefix, wildcard, and fuzzy MultiTerms. An
+// unterminated quote is treated as free text. Terms are produced by analyzer, which
+// must be the analyzer of the index being searched.
+func ParseQuery(raw string, fields []string, analyzer analysis.Analyzer) Query {
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+
+	var q Query
+	for len(raw) > 0 {
+		raw = strings.TrimLeftFunc(raw, unicode.IsSpace)
+		if raw == "" {
+			break
+		}
+
+		field := ""
+		if colon := strings.IndexByte(raw, ':'); colon > 0 && known[strings.ToLower(raw[:colon])] && !strings.ContainsFunc(raw[:colon], unicode.IsSpace) {
+			field = strings.ToLower(raw[:colon])
+			raw = raw[colon+1:]
+		}
+
+		if strings.HasPrefix(raw, `"`) {
+			if closing := strings.IndexByte(raw[1:], '"'); closing >= 0 {
+				terms, positions := analyzer.AnalyzePositions(raw[1 : closing+1])
+				phrase := Phrase{Terms: terms, Positions: phrasePositions(positions), Field: field}
+				raw = raw[closing+2:]
+				if strings.HasPrefix(raw, "~") {
+					digits := 1
+					for digits < len(raw) && raw[digits] >= '0' && raw[digits] <= '9' {
+						digits++
+					}
+					if slop, err := strconv.Atoi(raw[1:digits]); err == nil {
+						phrase.Slop = slop
+					}
+					raw = raw[digits:]
+				}
+				if len(phrase.Terms) > 0 {
+					q.Phrases = append(q.Phrases, phrase)
+				}
+				continue
+			}
+			raw = raw[1:]
+		}
+
+		end := strings.IndexFunc(raw, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(raw)
+		}
+		if multi, ok := parseMultiTerm(raw[:end], field); ok {
+			q.MultiTerms = append(q.MultiTerms, multi)
+		} else {
+			for _, token := range analyzer.Analyze(raw[:end]) {
+				q.Terms = append(q.Terms, Term{Text: token, Field: field})
+			}
+		}
+		raw = raw[end:]
+	}
+	return q
+}
+
+// Empty reports whether the query has nothing to match.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && len(q.MultiTerms) == 0
+}
+
+// AllTerms returns phrase terms followed by free terms, expansion terms, and expanded
+// multi-terms, for highlighting and for scorers that ignore term order.
+func (q Query) AllTerms() []string {
+	terms := make([]string, 0, len(q.Terms))
+	for _, phrase := range q.Phrases {
+		terms = append(terms, phrase.Terms...)
+	}
+	for _, term := range q.Terms {
+		terms = append(terms, term.Text)
+	}
+	for _, phrase := range q.Expansions {
+		terms = append(terms, phrase.Terms...)
+	}
+	for _, multi := range q.MultiTerms {
+		for _, term := range multi.Terms {
+			terms = append(terms, term.Text)
+		}
+	}
+	return terms
+}
+
+// phrasePositions turns analyzer positions into Phrase.Positions: relative to the first
+// term, or nil when no removed token separates the terms.
+func phrasePositions(positions []int) []int {
+	adjacent := true
+	for i := 1; i < len(positions); i++ {
+		adjacent = adjacent && positions[i] == positions[i-1]+1
+	}
+	if adjacent {
+		return nil
+	}
+	relative := make([]int, len(positions))
+	for i, pos := range positions {
+		relative[i] = pos - positions[0]
+	}
+	return relative
+}
+
+// matchPhrase counts the occurrences of a phrase given the positions of each of its
+// terms in one document. offsets are the phrase's Positions. Without slop every term
+// must sit exactly at its offset from the first. With slop, terms must appear in order
+// and the distance from the first to the last may differ from the phrase's by at most
+// slop tokens.
+func matchPhrase(positions [][]int, offsets []int, slop int) int {
+	if len(positions) == 0 {
+		return 0
+	}
+	offset := func(i int) int {
+		if offsets == nil {
+			return i
+		}
+		return offsets[i]
+	}
+	width := offset(len(positions) - 1)
+	matches := 0
+	for _, start := range positions[0] {
+		prev := start
+		ok := true
+		for i := 1; i < len(positions); i++ {
+			after := prev
+			if slop == 0 {
+				after = start + offset(i) - 1
+			}
+			next := firstAfter(positions[i], after)
+			if next < 0 || (slop == 0 && next != start+offset(i)) {
+				ok = false
+				break
+			}
+			prev = next
+		}
+		if ok && abs(prev-start-width) <= slop {
+			matches++
+		}
+	}
+	return matches
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// firstAfter returns the smallest position greater than after, or -1.
+func firstAfter(positions []int, after int) int {
+	lo, hi := 0, len(positions)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if positions[mid] <= after {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == len(positions) {
+		return -1
+	}
+	return positions[lo]
+}
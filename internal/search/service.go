@@ -3,8 +3,11 @@ package search
 import (
 	"math"
 	"sort"
-	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/index"
 	"github.com/eshwanth/distributed-search-engine/internal/semantic"
 )
@@ -18,83 +21,205 @@ type Result struct {
 	URL     string  `json:"url"`
 }
 
+// ImageResult represents a ranked image for a query.
+type ImageResult struct {
+	DocID     string  `json:"doc_id"`
+	Score     float64 `json:"score"`
+	ImageURL  string  `json:"image_url"`
+	Title     string  `json:"title"`
+	Alt       string  `json:"alt"`
+	Caption   string  `json:"caption"`
+	PageURL   string  `json:"page_url"`
+	PageTitle string  `json:"page_title"`
+}
+
+// FieldConfig tunes how matches in one field contribute to BM25F scores.
+type FieldConfig struct {
+	Weight float64
+	B      float64
+}
+
 // Service executes ranked search queries against the inverted index.
 type Service struct {
-	Index          *index.InvertedIndex
-	Semantic       *semantic.Index
-	K1             float64
-	B              float64
+	// Index is the page index searched when Shards is nil.
+	Index *index.InvertedIndex
+	// Shards, when set, replaces Index with a sharded page index searched in parallel.
+	Shards   *index.ShardedIndex
+	Semantic *semantic.Index
+	Images   *index.InvertedIndex
+	K1       float64
+	// B is the length normalization for fields without an entry in Fields.
+	B float64
+	// Fields holds per-field BM25F weights and length normalization. Fields missing from
+	// the map are scored with weight 1 and the service-wide B.
+	Fields         map[string]FieldConfig
 	LexicalWeight  float64
 	SemanticWeight float64
+	// ImageTextWeight and ImagePageWeight blend an image's own text score with the
+	// relevance of its parent page.
+	ImageTextWeight float64
+	ImagePageWeight float64
+	// SynonymWeight scales the score of terms added by synonym expansion relative to
+	// the terms the user typed. Zero disables expansion.
+	SynonymWeight float64
+	// MaxExpansions bounds how many indexed terms one prefix, wildcard, or fuzzy term
+	// expands to. Zero means no limit.
+	MaxExpansions int
+	// SuggestBelow is the result count under which SearchWithSuggestions looks for a
+	// spelling correction.
+	SuggestBelow int
+	// AutoCorrect makes SearchWithSuggestions return the results of the suggested
+	// query when the original query finds nothing.
+	AutoCorrect bool
+	// SpellRebuildInterval is the minimum time between rebuilds of the speller as the
+	// index changes.
+	SpellRebuildInterval time.Duration
+	// CompletionRebuildInterval is the minimum time between rebuilds of the title and
+	// term completions as the index changes.
+	CompletionRebuildInterval time.Duration
+	// QueryHistorySize bounds the number of distinct past queries kept for
+	// completion. Zero disables the history.
+	QueryHistorySize int
+
+	// Nodes, when set, makes the service a cluster coordinator: queries are sent to
+	// every node instead of the local shards, which then only supply the analyzer
+	// and fields used to parse them.
+	Nodes []ShardClient
+	// NodeTimeout bounds each phase of a cluster query. Nodes that fail or do not
+	// answer in time are left out and the response is marked partial.
+	NodeTimeout time.Duration
+
+	synonyms    atomic.Pointer[Synonyms]
+	spell       spellCache
+	completions completionCache
+	history     queryHistory
+}
+
+// Response is a page of search results with spelling help.
+type Response struct {
+	Results []Result `json:"results"`
+	// Suggestion is a corrected query that finds more results than the original.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Corrected reports that Results are for Suggestion because the original query
+	// found nothing.
+	Corrected bool `json:"corrected,omitempty"`
+	// Partial reports that some cluster nodes did not answer in time, so Results
+	// only cover the shards of the nodes that did.
+	Partial bool `json:"partial,omitempty"`
 }
 
-// NewService creates a search Service with BM25 defaults.
+// NewService creates a search Service with BM25F defaults.
 func NewService(idx *index.InvertedIndex, semanticIdx *semantic.Index) *Service {
 	return &Service{
-		Index:          idx,
-		Semantic:       semanticIdx,
-		K1:             1.5,
-		B:              0.75,
-		LexicalWeight:  1.0,
-		SemanticWeight: 0.65,
+		Index:    idx,
+		Semantic: semanticIdx,
+		K1:       1.5,
+		B:        0.75,
+		Fields: map[string]FieldConfig{
+			index.FieldTitle: {Weight: 3.0, B: 0.5},
+			index.FieldURL:   {Weight: 1.5, B: 0.5},
+			index.FieldBody:  {Weight: 1.0, B: 0.75},
+		},
+		LexicalWeight:             1.0,
+		SemanticWeight:            0.65,
+		ImageTextWeight:           1.0,
+		ImagePageWeight:           0.5,
+		SynonymWeight:             0.5,
+		MaxExpansions:             50,
+		SuggestBelow:              3,
+		SpellRebuildInterval:      30 * time.Second,
+		CompletionRebuildInterval: 30 * time.Second,
+		NodeTimeout:               time.Second,
+		QueryHistorySize:          10000,
 	}
 }
 
-// Search tokenizes the query, scores documents, and returns the topK results.
+// Search parses the query, scores documents, and returns the topK results. Quoted
+// phrases restrict results to documents containing them. Shards are searched in
+// parallel with statistics of the whole collection and their top results merged.
 func (s *Service) Search(query string, topK int) []Result {
-	if s.Index == nil {
-		return nil
+	results, _ := s.search(query, topK)
+	return results
+}
+
+// search runs a query against the local shards, or against Nodes when the service
+// coordinates a cluster, and reports whether some nodes failed to answer.
+func (s *Service) search(query string, topK int) ([]Result, bool) {
+	if len(s.Nodes) > 0 {
+		return s.searchNodes(query, topK)
 	}
-	tokens := index.Tokenize(query)
-	if len(tokens) == 0 {
-		return nil
+	readers := s.readers()
+	if len(readers) == 0 {
+		return nil, false
 	}
+	parsed, ok := s.parse(query)
+	if !ok {
+		return nil, false
+	}
+	parsed.MultiTerms = expandMultiTerms(readers, parsed.MultiTerms, s.MaxExpansions)
+	stats := globalStats(readers, parsed.AllTerms())
+	return s.searchReaders(readers, query, parsed, stats, topK), false
+}
 
-	docCount := float64(s.Index.DocumentCount())
-	avgDocLen := s.Index.AverageDocumentLength()
-	if avgDocLen == 0 {
-		avgDocLen = 1
+// parse turns raw query text into a query with synonyms expanded. It reports false
+// when there is nothing to search for.
+func (s *Service) parse(query string) (Query, bool) {
+	parsed := ParseQuery(query, s.fieldNames(), s.analyzer())
+	if parsed.Empty() {
+		return Query{}, false
 	}
+	return s.synonyms.Load().Expand(parsed, s.SynonymWeight), true
+}
 
-	lexicalScores := make(map[string]float64)
-	for _, term := range tokens {
-		postings := s.Index.Postings(term)
-		if len(postings) == 0 {
-			continue
-		}
-		df := float64(s.Index.DocumentFrequency(term))
-		if df == 0 {
-			continue
-		}
-		idf := math.Log((docCount - df + 0.5) / (df + 0.5))
-		if idf < 0 {
-			idf = 0
-		}
-		for _, posting := range postings {
-			doc, ok := s.Index.Document(posting.DocID)
-			if !ok {
-				continue
-			}
-			docLen := float64(len(doc.Tokens))
-			if docLen == 0 {
-				docLen = avgDocLen
-			}
-			numerator := posting.TF * (s.K1 + 1)
-			denominator := posting.TF + s.K1*(1-s.B+s.B*(docLen/avgDocLen))
-			score := idf * (numerator / denominator)
-			lexicalScores[posting.DocID] += score
+// searchReaders scores a parsed query on every reader in parallel, with stats for the
+// whole collection, and merges their topK results.
+func (s *Service) searchReaders(readers []*index.Reader, query string, parsed Query, stats collectionStats, topK int) []Result {
+	var semanticScores map[string]float64
+	if s.Semantic != nil {
+		semanticScores = make(map[string]float64)
+		for _, candidate := range s.Semantic.Query(query, max(topK, 10)) {
+			semanticScores[candidate.DocID] = candidate.Score
 		}
 	}
 
-	semanticScores := make(map[string]float64)
-	if s.Semantic != nil {
-		semanticLimit := topK
-		if semanticLimit < 10 {
-			semanticLimit = 10
+	analyzer := s.analyzer()
+	perShard := scatter(readers, func(reader *index.Reader) []Result {
+		return s.searchShard(reader, stats, parsed, semanticScores, analyzer, topK)
+	})
+	var results []Result
+	for _, shardResults := range perShard {
+		results = append(results, shardResults...)
+	}
+	sortResults(results)
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// searchShard scores the documents of one shard against collection-wide stats and
+// returns its topK results. Semantic scores may name documents of other shards; only
+// this shard's documents are used.
+func (s *Service) searchShard(reader *index.Reader, stats collectionStats, parsed Query, semanticScores map[string]float64, analyzer analysis.Analyzer, topK int) []Result {
+	lexicalScores := s.bm25f(reader, stats, parsed.Terms)
+	for docID, score := range s.multiTermScores(reader, stats, parsed.MultiTerms) {
+		lexicalScores[docID] += score
+	}
+	for _, expansion := range parsed.Expansions {
+		for docID, score := range s.phraseScore(reader, stats, expansion, nil) {
+			lexicalScores[docID] += score
 		}
-		candidates := s.Semantic.Query(query, semanticLimit)
-		for _, candidate := range candidates {
-			semanticScores[candidate.DocID] = candidate.Score
+	}
+	var phraseMatches map[string]float64
+	if len(parsed.Phrases) > 0 {
+		phraseMatches = s.phraseScores(reader, stats, parsed.Phrases)
+		for docID := range lexicalScores {
+			if _, ok := phraseMatches[docID]; !ok {
+				delete(lexicalScores, docID)
+			}
+		}
+		for docID, score := range phraseMatches {
+			lexicalScores[docID] += score
 		}
 	}
 
@@ -103,17 +228,123 @@ func (s *Service) Search(query string, topK int) []Result {
 		combined[docID] += s.LexicalWeight * lexical
 	}
 	for docID, sem := range semanticScores {
+		if phraseMatches != nil {
+			if _, ok := phraseMatches[docID]; !ok {
+				continue
+			}
+		}
 		combined[docID] += s.SemanticWeight * sem
 	}
 
+	tokens := parsed.AllTerms()
 	results := make([]Result, 0, len(combined))
 	for docID, score := range combined {
-		doc, ok := s.Index.Document(docID)
+		doc, ok := reader.Document(docID)
+		if !ok {
+			continue
+		}
+		results = append(results, Result{DocID: docID, Score: score, Title: doc.Title, URL: doc.URL})
+	}
+	sortResults(results)
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	// Snippets are only built for the results this shard can contribute.
+	for i := range results {
+		doc, _ := reader.Document(results[i].DocID)
+		results[i].Snippet = buildSnippet(doc.Content, tokens, analyzer)
+	}
+	return results
+}
+
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			return results[i].DocID < results[j].DocID
+		}
+		return results[i].Score > results[j].Score
+	})
+}
+
+// SearchWithSuggestions runs Search and, when it returns fewer than SuggestBelow
+// results, suggests a spelling correction built from the index term dictionary. A
+// correction is only suggested if it finds more results than the original query.
+// Spelling suggestions are not made when the service coordinates a cluster.
+func (s *Service) SearchWithSuggestions(query string, topK int) Response {
+	results, partial := s.search(query, topK)
+	resp := Response{Results: results, Partial: partial}
+	readers := s.readers()
+	if len(s.Nodes) > 0 || len(readers) == 0 || len(results) >= s.SuggestBelow {
+		// A coordinator only holds part of the term dictionary, so it cannot
+		// tell a misspelling from a term held by another node.
+		return resp
+	}
+	speller := s.spell.get(readers, s.SpellRebuildInterval)
+	suggestion, ok := speller.CorrectQuery(query, readers[0].FieldNames(), s.analyzer())
+	if !ok {
+		return resp
+	}
+	corrected := s.Search(suggestion, topK)
+	if len(corrected) <= len(results) {
+		return resp
+	}
+	resp.Suggestion = suggestion
+	if len(results) == 0 && s.AutoCorrect {
+		resp.Results = corrected
+		resp.Corrected = true
+	}
+	return resp
+}
+
+// SearchImages ranks image documents by BM25F over their alt text, title and caption,
+// boosted by the lexical relevance of the page each image was found on.
+func (s *Service) SearchImages(query string, topK int) []ImageResult {
+	if s.Images == nil {
+		return nil
+	}
+	terms := freeTerms(query, s.Images.Analyzer)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	images := s.Images.Reader()
+	imageScores := s.bm25f(images, images, terms)
+	pageScores := make(map[string]float64)
+	if readers := s.readers(); len(readers) > 0 && len(imageScores) > 0 {
+		pageTerms := freeTerms(query, s.analyzer())
+		texts := make([]string, len(pageTerms))
+		for i, term := range pageTerms {
+			texts[i] = term.Text
+		}
+		stats := globalStats(readers, texts)
+		for _, scores := range scatter(readers, func(reader *index.Reader) map[string]float64 {
+			return s.bm25f(reader, stats, pageTerms)
+		}) {
+			for docID, score := range scores {
+				pageScores[docID] = score
+			}
+		}
+	}
+
+	results := make([]ImageResult, 0, len(imageScores))
+	for docID, textScore := range imageScores {
+		doc, ok := images.Document(docID)
 		if !ok {
 			continue
 		}
-		snippet := buildSnippet(doc.Content, tokens)
-		results = append(results, Result{DocID: docID, Score: score, Title: doc.Title, URL: doc.URL, Snippet: snippet})
+		result := ImageResult{
+			DocID:    docID,
+			Score:    s.ImageTextWeight*textScore + s.ImagePageWeight*pageScores[doc.ParentID],
+			ImageURL: doc.URL,
+			Title:    doc.Title,
+		}
+		if doc.Image != nil {
+			result.Alt = doc.Image.Alt
+			result.Caption = doc.Image.Caption
+			result.PageURL = doc.Image.PageURL
+			result.PageTitle = doc.Image.PageTitle
+		}
+		results = append(results, result)
 	}
 
 	sort.Slice(results, func(i, j int) bool {
@@ -129,28 +360,185 @@ func (s *Service) Search(query string, topK int) []Result {
 	return results
 }
 
-func buildSnippet(content string, tokens []string) string {
+// bm25f scores every document in idx matching at least one term. Per-field term
+// frequencies are length normalized and weighted before a single saturation step, so a
+// term found in several fields is not rewarded as if it were several terms.
+func (s *Service) bm25f(idx *index.Reader, stats collectionStats, terms []Term) map[string]float64 {
+	docCount := float64(stats.DocumentCount())
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		fields := idx.FieldNames()
+		df := stats.DocumentFrequency(term.Text)
+		if term.Field != "" {
+			fields = []string{term.Field}
+			df = stats.FieldDocumentFrequency(term.Field, term.Text)
+		}
+		if df == 0 {
+			continue
+		}
+
+		weighted := make(map[string]float64)
+		for _, field := range fields {
+			cfg := s.fieldConfig(field)
+			if cfg.Weight == 0 {
+				continue
+			}
+			avgLen := stats.AverageFieldLength(field)
+			for _, posting := range idx.FieldPostings(field, term.Text) {
+				length := float64(idx.FieldLength(field, posting.DocID))
+				weighted[posting.DocID] += cfg.Weight * posting.TF / lengthNorm(cfg.B, length, avgLen)
+			}
+		}
+
+		idf := bm25IDF(docCount, float64(df)) * scoreWeight(term.Weight)
+		for docID, tf := range weighted {
+			scores[docID] += idf * s.saturate(tf)
+		}
+	}
+	return scores
+}
+
+// phraseScores returns a score for every document matching all phrases.
+func (s *Service) phraseScores(idx *index.Reader, stats collectionStats, phrases []Phrase) map[string]float64 {
+	var scores map[string]float64
+	for _, phrase := range phrases {
+		matched := s.phraseScore(idx, stats, phrase, scores)
+		for docID, score := range matched {
+			matched[docID] = scores[docID] + score
+		}
+		scores = matched
+	}
+	return scores
+}
+
+// phraseScore scores the documents containing one phrase, limited to the keys of
+// restrict when it is not nil. The phrase is scored like a BM25F term whose per-field
+// frequency is the number of phrase occurrences and whose IDF is the sum of its terms'
+// IDFs.
+func (s *Service) phraseScore(idx *index.Reader, stats collectionStats, phrase Phrase, restrict map[string]float64) map[string]float64 {
+	docCount := float64(stats.DocumentCount())
+	fields := idx.FieldNames()
+	if phrase.Field != "" {
+		fields = []string{phrase.Field}
+	}
+	var idf float64
+	for _, term := range phrase.Terms {
+		idf += bm25IDF(docCount, float64(stats.DocumentFrequency(term)))
+	}
+	idf *= scoreWeight(phrase.Weight)
+
+	weighted := make(map[string]float64)
+	for _, field := range fields {
+		cfg := s.fieldConfig(field)
+		avgLen := stats.AverageFieldLength(field)
+		for _, first := range idx.FieldPostings(field, phrase.Terms[0]) {
+			if restrict != nil {
+				if _, ok := restrict[first.DocID]; !ok {
+					continue
+				}
+			}
+			positions := make([][]int, len(phrase.Terms))
+			positions[0] = first.Positions
+			complete := true
+			for i := 1; i < len(phrase.Terms); i++ {
+				posting, ok := idx.FieldPosting(field, phrase.Terms[i], first.DocID)
+				if !ok {
+					complete = false
+					break
+				}
+				positions[i] = posting.Positions
+			}
+			if !complete {
+				continue
+			}
+			freq := matchPhrase(positions, phrase.Positions, phrase.Slop)
+			if freq == 0 {
+				continue
+			}
+			length := float64(idx.FieldLength(field, first.DocID))
+			weighted[first.DocID] += cfg.Weight * float64(freq) / lengthNorm(cfg.B, length, avgLen)
+		}
+	}
+
+	scores := make(map[string]float64, len(weighted))
+	for docID, tf := range weighted {
+		scores[docID] = idf * s.saturate(tf)
+	}
+	return scores
+}
+
+func (s *Service) fieldConfig(field string) FieldConfig {
+	if cfg, ok := s.Fields[field]; ok {
+		return cfg
+	}
+	return FieldConfig{Weight: 1, B: s.B}
+}
+
+// bm25IDF returns the non-negative BM25 inverse document frequency.
+func bm25IDF(docCount, df float64) float64 {
+	if df == 0 {
+		return 0
+	}
+	idf := math.Log((docCount - df + 0.5) / (df + 0.5))
+	if idf < 0 {
+		return 0
+	}
+	return idf
+}
+
+// lengthNorm returns the BM25 length normalization factor for one field.
+func lengthNorm(b, length, avgLen float64) float64 {
+	if avgLen == 0 {
+		return 1
+	}
+	return 1 - b + b*(length/avgLen)
+}
+
+// saturate applies BM25 term frequency saturation to a normalized frequency.
+func (s *Service) saturate(tf float64) float64 {
+	return tf * (s.K1 + 1) / (tf + s.K1)
+}
+
+func buildSnippet(content string, tokens []string, analyzer analysis.Analyzer) string {
 	if len(content) == 0 {
 		return ""
 	}
 
-	lower := strings.ToLower(content)
+	wanted := make(map[string]struct{}, len(tokens))
 	for _, token := range tokens {
-		pos := strings.Index(lower, token)
-		if pos >= 0 {
-			start := pos - 40
-			if start < 0 {
-				start = 0
-			}
-			end := pos + 40
-			if end > len(content) {
-				end = len(content)
+		wanted[token] = struct{}{}
+	}
+	// Words are analyzed one at a time so stemmed or accent-folded query terms still
+	// find their original spelling in the content.
+	for _, span := range (analysis.UnicodeTokenizer{}).Spans(content) {
+		for _, term := range analyzer.Analyze(content[span[0]:span[1]]) {
+			if _, ok := wanted[term]; ok {
+				return snippetWindow(content, span[0]-40, span[0]+40)
 			}
-			return content[start:end]
 		}
 	}
-	if len(content) > 80 {
-		return content[:80]
+	return snippetWindow(content, 0, 80)
+}
+
+// snippetWindow returns content[start:end], clamped to the content and widened to
+// rune boundaries so multi-byte characters are never split.
+func snippetWindow(content string, start, end int) string {
+	start = max(start, 0)
+	end = min(end, len(content))
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+	return content[start:end]
+}
+
+// freeTerms analyzes query with analyzer and returns every term as an unfielded term.
+func freeTerms(query string, analyzer analysis.Analyzer) []Term {
+	var terms []Term
+	for _, text := range ParseQuery(query, nil, analyzer).AllTerms() {
+		terms = append(terms, Term{Text: text})
 	}
-	return content
+	return terms
 }
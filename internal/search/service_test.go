@@ -1,8 +1,13 @@
 package search_test
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
 	"github.com/eshwanth/distributed-search-engine/internal/index"
 	"github.com/eshwanth/distributed-search-engine/internal/search"
@@ -47,3 +52,189 @@ func TestSemanticBoostsNonLexicalDocument(t *testing.T) {
 		t.Fatalf("expected semantic document to rank first, got %s", results[0].DocID)
 	}
 }
+
+func TestSearchImagesUsesAltTextAndParentRelevance(t *testing.T) {
+	pages := index.NewInvertedIndex()
+	pages.AddDocument(&docs.Document{ID: "p1", Title: "Raft", Content: "Raft consensus leader election explained."})
+	pages.AddDocument(&docs.Document{ID: "p2", Title: "Gardening", Content: "Growing tomatoes in the garden."})
+
+	images := index.NewInvertedIndex()
+	images.AddDocument(&docs.Document{ID: "i1", Type: docs.TypeImage, ParentID: "p1", URL: "http://example.com/raft.png", Content: "leader diagram",
+		Image: &docs.ImageInfo{Alt: "leader diagram", PageURL: "http://example.com/raft"}})
+	images.AddDocument(&docs.Document{ID: "i2", Type: docs.TypeImage, ParentID: "p2", URL: "http://example.com/tomato.png", Content: "leader of the tomato patch"})
+	images.AddDocument(&docs.Document{ID: "i3", Type: docs.TypeImage, ParentID: "p2", URL: "http://example.com/shovel.png", Content: "shovel"})
+
+	svc := search.NewService(pages, nil)
+	svc.Images = images
+	results := svc.SearchImages("raft leader", 5)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 image results, got %d", len(results))
+	}
+	if results[0].DocID != "i1" {
+		t.Fatalf("expected image on relevant page to rank first, got %s", results[0].DocID)
+	}
+	if results[0].PageURL != "http://example.com/raft" || results[0].ImageURL != "http://example.com/raft.png" {
+		t.Fatalf("unexpected image result metadata: %+v", results[0])
+	}
+}
+
+func TestSearchHonorsQuotedPhrases(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "exact", Content: "Vector search engines rank embeddings."})
+	idx.AddDocument(&docs.Document{ID: "reversed", Content: "Search for vector graphics editors."})
+	idx.AddDocument(&docs.Document{ID: "near", Content: "Vector based search across documents."})
+	idx.AddDocument(&docs.Document{ID: "other", Content: "Circuit breakers protect services."})
+
+	svc := search.NewService(idx, nil)
+	assertIDs := func(query string, want ...string) {
+		t.Helper()
+		results := svc.Search(query, 10)
+		got := make(map[string]bool, len(results))
+		for _, r := range results {
+			got[r.DocID] = true
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %v, got %+v", query, want, results)
+		}
+		for _, id := range want {
+			if !got[id] {
+				t.Fatalf("%s: expected %v, got %+v", query, want, results)
+			}
+		}
+	}
+
+	assertIDs(`"vector search"`, "exact")
+	assertIDs(`"vector search"~1`, "exact", "near")
+	assertIDs(`"vector search" engines`, "exact")
+}
+
+func TestPhrasesKeepRemovedStopwordPositions(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.Analyzer = analysis.English()
+	idx.AddDocument(&docs.Document{ID: "exact", Content: "A state of the art search engine."})
+	idx.AddDocument(&docs.Document{ID: "other", Content: "The state and art museum."})
+	idx.AddDocument(&docs.Document{ID: "adjacent", Content: "State art is rare."})
+	idx.Refresh()
+	svc := search.NewService(idx, nil)
+
+	results := svc.Search(`"state of the art"`, 5)
+	if len(results) != 1 || results[0].DocID != "exact" {
+		t.Fatalf("expected only the exact phrase to match, got %+v", results)
+	}
+	if results := svc.Search(`"state art"`, 5); len(results) != 1 || results[0].DocID != "adjacent" {
+		t.Fatalf("expected a stopword gap to break adjacency, got %+v", results)
+	}
+}
+
+func TestSearchUsesIndexAnalyzer(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.Analyzer = analysis.English()
+	idx.AddDocument(&docs.Document{ID: "en", Title: "Connections", Content: "The broker was connecting clients to partitions."})
+	idx.AddDocument(&docs.Document{ID: "fr", Title: "Café Société", Content: "Un café près de la gare, rue Гоголя."})
+	svc := search.NewService(idx, nil)
+
+	results := svc.Search("connected partition", 5)
+	if len(results) != 1 || results[0].DocID != "en" {
+		t.Fatalf("expected stemmed match, got %+v", results)
+	}
+	if results[0].Snippet == "" || results[0].Snippet[:3] != "The" {
+		t.Fatalf("expected snippet around the original spelling, got %q", results[0].Snippet)
+	}
+	for _, query := range []string{"cafe", "CAFÉ", "societe", "гоголя"} {
+		if results := svc.Search(query, 5); len(results) != 1 || results[0].DocID != "fr" {
+			t.Fatalf("expected %q to match the accented document, got %+v", query, results)
+		}
+	}
+}
+
+func TestSearchMatchesCJKText(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "zh", Title: "分布式搜索引擎", Content: "我们用Kafka构建分布式搜索引擎，支持实时索引。"})
+	idx.AddDocument(&docs.Document{ID: "ja", Title: "東京大学", Content: "東京大学で機械学習を学ぶ。"})
+	svc := search.NewService(idx, nil)
+
+	for query, want := range map[string]string{"搜索引擎": "zh", "kafka 实时": "zh", "機械学習": "ja", `"東京大学"`: "ja"} {
+		results := svc.Search(query, 5)
+		if len(results) == 0 || results[0].DocID != want {
+			t.Fatalf("expected %q to rank %s first, got %+v", query, want, results)
+		}
+		if !utf8.ValidString(results[0].Snippet) {
+			t.Fatalf("expected snippet on rune boundaries, got %q", results[0].Snippet)
+		}
+	}
+	if results := svc.Search(`"大学東京"`, 5); len(results) != 0 {
+		t.Fatalf("expected reordered phrase not to match, got %+v", results)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q := search.ParseQuery(`kafka "exactly once"~2 title:delivery url:"event log" http://x "unterminated`, []string{index.FieldTitle, index.FieldURL}, analysis.Standard())
+	if len(q.Phrases) != 2 || q.Phrases[0].Slop != 2 || len(q.Phrases[0].Terms) != 2 || q.Phrases[0].Terms[1] != "once" {
+		t.Fatalf("unexpected phrases: %+v", q.Phrases)
+	}
+	if q.Phrases[1].Field != index.FieldURL {
+		t.Fatalf("expected field-restricted phrase, got %+v", q.Phrases[1])
+	}
+	want := []search.Term{{Text: "kafka"}, {Text: "delivery", Field: index.FieldTitle}, {Text: "http"}, {Text: "x"}, {Text: "unterminated"}}
+	if len(q.Terms) != len(want) {
+		t.Fatalf("expected terms %v, got %v", want, q.Terms)
+	}
+	for i := range want {
+		if q.Terms[i] != want[i] {
+			t.Fatalf("expected terms %v, got %v", want, q.Terms)
+		}
+	}
+}
+
+func TestSearchWeightsTitleMatchesAndFieldRestrictions(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "title", Title: "Kafka Operations", URL: "https://docs.internal/ops", Content: "Rolling restarts of brokers keep partitions available."})
+	idx.AddDocument(&docs.Document{ID: "body", Title: "Messaging Overview", URL: "https://docs.internal/messaging", Content: "Queues, pub/sub and kafka compared for event delivery."})
+	idx.AddDocument(&docs.Document{ID: "other1", Title: "Caching", Content: "Cache aside and write through strategies."})
+	idx.AddDocument(&docs.Document{ID: "other2", Title: "Tracing", Content: "Distributed tracing with spans and baggage."})
+	idx.AddDocument(&docs.Document{ID: "other3", Title: "Consensus", Content: "Raft elects a leader per term."})
+	idx.AddDocument(&docs.Document{ID: "other4", Title: "Sharding", Content: "Hash partitioning spreads keys across nodes."})
+
+	svc := search.NewService(idx, nil)
+	results := svc.Search("kafka", 5)
+	if len(results) != 2 || results[0].DocID != "title" {
+		t.Fatalf("expected title match to rank first, got %+v", results)
+	}
+
+	results = svc.Search("title:kafka", 5)
+	if len(results) != 1 || results[0].DocID != "title" {
+		t.Fatalf("expected only the title match, got %+v", results)
+	}
+
+	results = svc.Search("url:messaging", 5)
+	if len(results) != 1 || results[0].DocID != "body" {
+		t.Fatalf("expected only the url match, got %+v", results)
+	}
+}
+
+func TestShardedSearchMatchesSingleIndex(t *testing.T) {
+	single := index.NewInvertedIndex()
+	sharded := index.NewShardedIndex(4)
+	topics := []string{"kafka consumer groups", "raft leader election", "kafka log compaction", "vector search with kafka"}
+	for i := 0; i < 30; i++ {
+		doc := docs.Document{ID: fmt.Sprintf("doc-%02d", i), Title: topics[i%len(topics)], Content: strings.Repeat(topics[(i+1)%len(topics)]+" ", 1+i%3)}
+		single.AddDocument(&doc)
+		copied := doc
+		sharded.AddDocument(&copied)
+	}
+	want := search.NewService(single, nil)
+	got := search.NewService(nil, nil)
+	got.Shards = sharded
+
+	for _, query := range []string{"kafka", "title:raft election", `"log compaction"`, "kafak~1 vector"} {
+		expected, results := want.Search(query, 5), got.Search(query, 5)
+		if len(results) != len(expected) || len(results) == 0 {
+			t.Fatalf("%q: expected %d results, got %d", query, len(expected), len(results))
+		}
+		for i := range expected {
+			if results[i].DocID != expected[i].DocID || math.Abs(results[i].Score-expected[i].Score) > 1e-9 {
+				t.Fatalf("%q: expected %+v, got %+v", query, expected, results)
+			}
+		}
+	}
+}
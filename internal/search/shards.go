@@ -0,0 +1,93 @@
+package search
+
+import (
+	"sync"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+)
+
+// collectionStats are the statistics BM25F normalizes by. A single index reader
+// supplies its own; shards share the statistics of the whole collection, so a document
+// scores the same whichever shard holds it.
+type collectionStats interface {
+	DocumentCount() int
+	DocumentFrequency(term string) int
+	FieldDocumentFrequency(field, term string) int
+	AverageFieldLength(field string) float64
+}
+
+// readers returns a point-in-time reader for each page shard, or for Index when the
+// service is not sharded.
+func (s *Service) readers() []*index.Reader {
+	switch {
+	case s.Shards != nil:
+		return s.Shards.Readers()
+	case s.Index != nil:
+		return []*index.Reader{s.Index.Reader()}
+	}
+	return nil
+}
+
+// fieldNames returns the fields of the page index.
+func (s *Service) fieldNames() []string {
+	if s.Shards != nil {
+		return s.Shards.Shards()[0].FieldNames()
+	}
+	return s.Index.FieldNames()
+}
+
+// analyzer returns the analyzer of the page index.
+func (s *Service) analyzer() analysis.Analyzer {
+	if s.Shards != nil {
+		return s.Shards.Analyzer()
+	}
+	return s.Index.Analyzer
+}
+
+// globalStats gathers the statistics of terms across readers. A single reader is its
+// own statistics.
+func globalStats(readers []*index.Reader, terms []string) collectionStats {
+	if len(readers) == 1 {
+		return readers[0]
+	}
+	return mergedStats(readers, terms)
+}
+
+// mergedStats sums the statistics of terms over readers.
+func mergedStats(readers []*index.Reader, terms []string) index.Stats {
+	var stats index.Stats
+	for _, shard := range scatter(readers, func(r *index.Reader) index.Stats { return r.Stats(terms) }) {
+		stats.Merge(shard)
+	}
+	return stats
+}
+
+// scatter runs fn on every reader in parallel and returns the results in reader order.
+func scatter[T any](readers []*index.Reader, fn func(*index.Reader) T) []T {
+	out := make([]T, len(readers))
+	if len(readers) == 1 {
+		out[0] = fn(readers[0])
+		return out
+	}
+	var wg sync.WaitGroup
+	for i, reader := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = fn(reader)
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// readersVersion changes whenever any reader's segments change, because every shard's
+// version only grows.
+func readersVersion(readers []*index.Reader) uint64 {
+	var version uint64
+	for _, r := range readers {
+		version += r.Version()
+	}
+	return version
+}
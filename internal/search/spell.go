@@ -0,0 +1,210 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+)
+
+// Speller corrects misspelled query terms against an index term dictionary. Candidates
+// are found with a BK-tree over Levenshtein distance and ranked by a noisy-channel
+// score: each edit costs a fixed penalty and more frequent terms are preferred, so a
+// common term two edits away can beat a rare one a single edit away.
+type Speller struct {
+	root *bkNode
+	freq map[string]int
+}
+
+type bkNode struct {
+	term     string
+	children map[int]*bkNode
+}
+
+// editPenalty is the log-frequency cost of one edit. A candidate one edit further away
+// must be about e^editPenalty times more frequent to win.
+const editPenalty = 4.0
+
+// rareRatio is how much more frequent a close candidate must be before a term that
+// does occur in the index is considered a misspelling.
+const rareRatio = 50
+
+// NewSpeller builds a speller from an index term dictionary. Terms containing digits
+// and single-character terms are skipped because they are rarely typos.
+func NewSpeller(terms []index.TermFrequency) *Speller {
+	s := &Speller{freq: make(map[string]int, len(terms))}
+	for _, tf := range terms {
+		s.freq[tf.Term] = tf.DocFreq
+		if !correctable(tf.Term) {
+			continue
+		}
+		s.insert(tf.Term)
+	}
+	return s
+}
+
+func correctable(term string) bool {
+	runes := 0
+	for _, r := range term {
+		if unicode.IsDigit(r) {
+			return false
+		}
+		runes++
+	}
+	return runes > 1
+}
+
+func (s *Speller) insert(term string) {
+	if s.root == nil {
+		s.root = &bkNode{term: term}
+		return
+	}
+	node := s.root
+	for {
+		d := levenshtein(term, node.term)
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{term: term}
+			return
+		}
+		node = child
+	}
+}
+
+// maxEdits allows one edit in short terms and two in longer ones, which keeps short
+// terms from being "corrected" into unrelated words.
+func maxEdits(term string) int {
+	if len([]rune(term)) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// Correct returns the best replacement for term and true, or false when term looks
+// correctly spelled or nothing close enough exists.
+func (s *Speller) Correct(term string) (string, bool) {
+	if s == nil || s.root == nil || !correctable(term) {
+		return "", false
+	}
+	own := s.freq[term]
+	limit := maxEdits(term)
+	if own > 0 {
+		// A known term is only replaced by a far more common term one edit away.
+		limit = 1
+	}
+
+	best, bestScore := "", math.Inf(-1)
+	stack := []*bkNode{s.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := levenshtein(term, node.term)
+		if d > 0 && d <= limit {
+			score := math.Log(float64(s.freq[node.term])) - editPenalty*float64(d)
+			if score > bestScore || (score == bestScore && node.term < best) {
+				best, bestScore = node.term, score
+			}
+		}
+		for dist, child := range node.children {
+			if dist >= d-limit && dist <= d+limit {
+				stack = append(stack, child)
+			}
+		}
+	}
+	if best == "" || (own > 0 && s.freq[best] < own*rareRatio) {
+		return "", false
+	}
+	return best, true
+}
+
+// levenshtein returns the edit distance between a and b in runes.
+func levenshtein(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// CorrectQuery rewrites the words of a raw query that the speller corrects, keeping
+// quotes, field prefixes, and operators as typed. It reports false when nothing
+// changed. Each word is analyzed on its own, so corrections are index terms: with a
+// stemming analyzer a suggestion may show a stem.
+func (s *Speller) CorrectQuery(query string, fields []string, analyzer analysis.Analyzer) (string, bool) {
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+	var out strings.Builder
+	last, changed := 0, false
+	for _, span := range (analysis.UnicodeTokenizer{}).Spans(query) {
+		word := query[span[0]:span[1]]
+		if strings.HasPrefix(query[span[1]:], ":") && known[strings.ToLower(word)] {
+			continue
+		}
+		if strings.ContainsAny(query[span[0]-min(span[0], 1):min(span[1]+1, len(query))], "*?~") {
+			// Prefix, wildcard, and fuzzy terms already match inexactly.
+			continue
+		}
+		terms := analyzer.Analyze(word)
+		if len(terms) != 1 {
+			continue
+		}
+		correction, ok := s.Correct(terms[0])
+		if !ok {
+			continue
+		}
+		out.WriteString(query[last:span[0]])
+		out.WriteString(correction)
+		last, changed = span[1], true
+	}
+	if !changed {
+		return "", false
+	}
+	out.WriteString(query[last:])
+	return out.String(), true
+}
+
+// spellCache rebuilds the speller when the index has changed, at most once per
+// interval, because building it walks the whole term dictionary.
+type spellCache struct {
+	mu      sync.Mutex
+	speller *Speller
+	version uint64
+	built   time.Time
+}
+
+func (c *spellCache) get(readers []*index.Reader, interval time.Duration) *Speller {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version := readersVersion(readers)
+	if c.speller == nil || (version != c.version && time.Since(c.built) >= interval) {
+		c.speller = NewSpeller(index.MergeTerms(readers))
+		c.version = version
+		c.built = time.Now()
+	}
+	return c.speller
+}
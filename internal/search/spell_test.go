@@ -0,0 +1,73 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+)
+
+func TestSpellerPrefersCloseFrequentTerms(t *testing.T) {
+	speller := search.NewSpeller([]index.TermFrequency{
+		{Term: "kafka", DocFreq: 60},
+		{Term: "kafta", DocFreq: 1},
+		{Term: "distributed", DocFreq: 25},
+		{Term: "distribute", DocFreq: 2},
+		{Term: "raft", DocFreq: 30},
+		{Term: "rafts", DocFreq: 1},
+		{Term: "http2", DocFreq: 5},
+	})
+	cases := []struct {
+		term, want string
+		ok         bool
+	}{
+		{"kafak", "kafka", true},
+		{"distribited", "distributed", true},
+		{"raft", "", false},
+		{"rafts", "", false},
+		{"kafta", "kafka", true},
+		{"zzzzzz", "", false},
+		{"http3", "", false},
+	}
+	for _, c := range cases {
+		got, ok := speller.Correct(c.term)
+		if got != c.want || ok != c.ok {
+			t.Errorf("Correct(%q) = %q, %v; want %q, %v", c.term, got, ok, c.want, c.ok)
+		}
+	}
+
+	got, ok := speller.CorrectQuery(`title:Kafak "distribited raft"~2`, []string{index.FieldTitle}, analysis.Standard())
+	if !ok || got != `title:kafka "distributed raft"~2` {
+		t.Fatalf("unexpected corrected query %q, %v", got, ok)
+	}
+}
+
+func TestSearchWithSuggestions(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "1", Title: "Kafka consumers", Content: "Kafka consumer groups balance partitions."})
+	idx.AddDocument(&docs.Document{ID: "2", Title: "Kafka producers", Content: "Kafka producers batch records."})
+	idx.AddDocument(&docs.Document{ID: "3", Title: "Raft", Content: "Raft elects a leader."})
+	svc := search.NewService(idx, nil)
+	svc.SpellRebuildInterval = 0
+
+	resp := svc.SearchWithSuggestions("kafak", 5)
+	if len(resp.Results) != 0 || resp.Suggestion != "kafka" || resp.Corrected {
+		t.Fatalf("expected a suggestion without results, got %+v", resp)
+	}
+	if resp := svc.SearchWithSuggestions("kafka", 5); resp.Suggestion != "" || len(resp.Results) != 2 {
+		t.Fatalf("expected no suggestion for a correct query, got %+v", resp)
+	}
+
+	svc.AutoCorrect = true
+	resp = svc.SearchWithSuggestions("kafak", 5)
+	if !resp.Corrected || len(resp.Results) != 2 || resp.Suggestion != "kafka" {
+		t.Fatalf("expected corrected results, got %+v", resp)
+	}
+
+	idx.AddDocument(&docs.Document{ID: "4", Content: "Zookeeper coordinates brokers."})
+	if resp := svc.SearchWithSuggestions("zookeepr", 5); resp.Suggestion != "zookeeper" {
+		t.Fatalf("expected speller to pick up new terms, got %+v", resp)
+	}
+}
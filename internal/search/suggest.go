@@ -0,0 +1,337 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+)
+
+// Completion sources.
+const (
+	CompletionQuery = "query"
+	CompletionTitle = "title"
+	CompletionTerm  = "term"
+)
+
+// MaxCompletions is the largest number of completions Complete returns.
+const MaxCompletions = 10
+
+// Completion is a suggested query for a typed prefix.
+type Completion struct {
+	Text   string  `json:"text"`
+	Source string  `json:"source"`
+	Score  float64 `json:"score"`
+}
+
+// Source weights put popular past queries ahead of titles, and titles ahead of single
+// terms, unless the lower source is far more frequent.
+const (
+	queryCompletionWeight = 3.0
+	titleCompletionWeight = 2.0
+	termCompletionWeight  = 1.0
+)
+
+// foldText lowercases and accent folds text like the standard analyzer and collapses
+// whitespace, so typed prefixes and patterns match index terms, titles, and past
+// queries without being tokenized.
+func foldText(text string) string {
+	words := strings.Fields(text)
+	words = analysis.LowercaseFilter{}.FilterTokens(words)
+	words = analysis.AccentFilter{}.FilterTokens(words)
+	return strings.Join(words, " ")
+}
+
+// completionTrie is a byte trie whose nodes keep their best completions, so a lookup
+// costs one step per prefix byte regardless of how many keys share the prefix.
+type completionTrie struct {
+	nodes   []trieNode
+	entries []Completion
+}
+
+type trieNode struct {
+	edges []trieEdge
+	// top holds up to MaxCompletions entry indexes under this node, best first.
+	top []int32
+}
+
+type trieEdge struct {
+	label byte
+	node  int32
+}
+
+// newCompletionTrie indexes entries by key. Entries sharing a key keep the best score.
+func newCompletionTrie(keys []string, entries []Completion) *completionTrie {
+	t := &completionTrie{nodes: []trieNode{{}}}
+	terminal := make(map[int32]int32, len(keys))
+	for i, key := range keys {
+		node := t.insert(key)
+		prev, ok := terminal[node]
+		switch {
+		case !ok:
+			terminal[node] = int32(len(t.entries))
+			t.entries = append(t.entries, entries[i])
+		case entries[i].Score > t.entries[prev].Score:
+			t.entries[prev] = entries[i]
+		}
+	}
+	t.collect(0, terminal)
+	return t
+}
+
+func (t *completionTrie) insert(key string) int32 {
+	var node int32
+	for i := 0; i < len(key); i++ {
+		edges := t.nodes[node].edges
+		j := sort.Search(len(edges), func(j int) bool { return edges[j].label >= key[i] })
+		if j < len(edges) && edges[j].label == key[i] {
+			node = edges[j].node
+			continue
+		}
+		child := int32(len(t.nodes))
+		t.nodes = append(t.nodes, trieNode{})
+		edges = append(edges, trieEdge{})
+		copy(edges[j+1:], edges[j:])
+		edges[j] = trieEdge{label: key[i], node: child}
+		t.nodes[node].edges = edges
+		node = child
+	}
+	return node
+}
+
+// collect fills in the best completions of node and its descendants.
+func (t *completionTrie) collect(node int32, terminal map[int32]int32) []int32 {
+	var candidates []int32
+	if entry, ok := terminal[node]; ok {
+		candidates = append(candidates, entry)
+	}
+	for _, edge := range t.nodes[node].edges {
+		candidates = append(candidates, t.collect(edge.node, terminal)...)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return betterCompletion(t.entries[candidates[i]], t.entries[candidates[j]])
+	})
+	if len(candidates) > MaxCompletions {
+		candidates = candidates[:MaxCompletions:MaxCompletions]
+	}
+	t.nodes[node].top = candidates
+	return candidates
+}
+
+// betterCompletion orders completions by score, then alphabetically.
+func betterCompletion(a, b Completion) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Text < b.Text
+}
+
+// lookup returns the best completions of keys starting with prefix.
+func (t *completionTrie) lookup(prefix string) []Completion {
+	if t == nil {
+		return nil
+	}
+	var node int32
+	for i := 0; i < len(prefix); i++ {
+		edges := t.nodes[node].edges
+		j := sort.Search(len(edges), func(j int) bool { return edges[j].label >= prefix[i] })
+		if j == len(edges) || edges[j].label != prefix[i] {
+			return nil
+		}
+		node = edges[j].node
+	}
+	out := make([]Completion, len(t.nodes[node].top))
+	for i, entry := range t.nodes[node].top {
+		out[i] = t.entries[entry]
+	}
+	return out
+}
+
+// indexCompletions holds the completions drawn from one index version: titles
+// complete the whole typed text and terms complete its last word.
+type indexCompletions struct {
+	titles *completionTrie
+	terms  *completionTrie
+}
+
+func buildIndexCompletions(readers []*index.Reader) *indexCompletions {
+	titleCount := make(map[string]int)
+	titleText := make(map[string]string)
+	for _, reader := range readers {
+		for _, doc := range reader.Documents() {
+			key := foldText(doc.Title)
+			if key == "" {
+				continue
+			}
+			titleCount[key]++
+			if _, ok := titleText[key]; !ok {
+				titleText[key] = strings.Join(strings.Fields(doc.Title), " ")
+			}
+		}
+	}
+	titleKeys := make([]string, 0, len(titleCount))
+	titles := make([]Completion, 0, len(titleCount))
+	for key, n := range titleCount {
+		titleKeys = append(titleKeys, key)
+		titles = append(titles, Completion{Text: titleText[key], Source: CompletionTitle, Score: titleCompletionWeight * math.Log1p(float64(n))})
+	}
+
+	terms := index.MergeTerms(readers)
+	termKeys := make([]string, 0, len(terms))
+	termEntries := make([]Completion, 0, len(terms))
+	for _, tf := range terms {
+		if len(tf.Term) < 2 {
+			continue
+		}
+		termKeys = append(termKeys, tf.Term)
+		termEntries = append(termEntries, Completion{Text: tf.Term, Source: CompletionTerm, Score: termCompletionWeight * math.Log1p(float64(tf.DocFreq))})
+	}
+	return &indexCompletions{
+		titles: newCompletionTrie(titleKeys, titles),
+		terms:  newCompletionTrie(termKeys, termEntries),
+	}
+}
+
+// completionCache rebuilds the index completions when the index has changed, at most
+// once per interval. Only the first build blocks; later rebuilds run in the background
+// while the previous completions keep serving.
+type completionCache struct {
+	mu          sync.Mutex
+	completions *indexCompletions
+	version     uint64
+	built       time.Time
+	building    bool
+}
+
+func (c *completionCache) get(readers []*index.Reader, interval time.Duration) *indexCompletions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version := readersVersion(readers)
+	if c.completions == nil {
+		c.completions = buildIndexCompletions(readers)
+		c.version, c.built = version, time.Now()
+		return c.completions
+	}
+	if version != c.version && !c.building && time.Since(c.built) >= interval {
+		c.building = true
+		go func() {
+			completions := buildIndexCompletions(readers)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.completions = completions
+			c.version, c.built = version, time.Now()
+			c.building = false
+		}()
+	}
+	return c.completions
+}
+
+// queryHistory counts successful queries. When full, a new query replaces the least
+// frequent one, so popular queries survive a long tail of one-off searches.
+type queryHistory struct {
+	mu     sync.RWMutex
+	counts map[string]*historyEntry
+}
+
+type historyEntry struct {
+	text  string
+	count int
+}
+
+func (h *queryHistory) record(query string, limit int) {
+	key := foldText(query)
+	if key == "" || limit <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if entry, ok := h.counts[key]; ok {
+		entry.count++
+		return
+	}
+	if h.counts == nil {
+		h.counts = make(map[string]*historyEntry)
+	}
+	for len(h.counts) >= limit {
+		victim, fewest := "", math.MaxInt
+		for k, entry := range h.counts {
+			if entry.count < fewest || (entry.count == fewest && k < victim) {
+				victim, fewest = k, entry.count
+			}
+		}
+		delete(h.counts, victim)
+	}
+	h.counts[key] = &historyEntry{text: strings.Join(strings.Fields(query), " "), count: 1}
+}
+
+// lookup scans the history for keys starting with prefix. The history is bounded by
+// QueryHistorySize, which keeps the scan well under a millisecond.
+func (h *queryHistory) lookup(prefix string) []Completion {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var out []Completion
+	for key, entry := range h.counts {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, Completion{Text: entry.text, Source: CompletionQuery, Score: queryCompletionWeight * math.Log1p(float64(entry.count))})
+		}
+	}
+	return out
+}
+
+// RecordQuery adds a query that found results to the history used by Complete.
+func (s *Service) RecordQuery(query string) {
+	s.history.record(query, s.QueryHistorySize)
+}
+
+// Complete returns up to limit completions of a partially typed query, best first.
+// Past queries and document titles complete the whole text; index terms complete its
+// last word. A prefix ending in a space only completes to longer queries and titles.
+func (s *Service) Complete(prefix string, limit int) []Completion {
+	key := foldText(prefix)
+	if key == "" || limit <= 0 {
+		return nil
+	}
+	limit = min(limit, MaxCompletions)
+	// A trailing space asks for the next word, so the typed words must be complete.
+	wordDone := strings.TrimRightFunc(prefix, unicode.IsSpace) != prefix
+	phrase := key
+	if wordDone {
+		phrase += " "
+	}
+
+	candidates := s.history.lookup(phrase)
+	if readers := s.readers(); len(readers) > 0 {
+		completions := s.completions.get(readers, s.CompletionRebuildInterval)
+		candidates = append(candidates, completions.titles.lookup(phrase)...)
+		if !wordDone {
+			last := strings.LastIndexByte(key, ' ') + 1
+			head := key[:last]
+			for _, c := range completions.terms.lookup(key[last:]) {
+				c.Text = head + c.Text
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	best := make(map[string]Completion, len(candidates))
+	for _, c := range candidates {
+		k := foldText(c.Text)
+		if prev, ok := best[k]; !ok || c.Score > prev.Score {
+			best[k] = c
+		}
+	}
+	out := make([]Completion, 0, len(best))
+	for _, c := range best {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return betterCompletion(out[i], out[j]) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package search_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+)
+
+func completionTexts(completions []search.Completion) []string {
+	texts := make([]string, len(completions))
+	for i, c := range completions {
+		texts[i] = c.Text
+	}
+	return texts
+}
+
+func TestCompleteRanksQueriesTitlesAndTerms(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "1", Title: "Kafka  Consumers", Content: "Kafka consumer groups balance partitions."})
+	idx.AddDocument(&docs.Document{ID: "2", Title: "Kafka producers", Content: "Kafka producers batch records into partitions."})
+	idx.AddDocument(&docs.Document{ID: "3", Title: "Raft", Content: "Raft elects a leader for each partition."})
+	svc := search.NewService(idx, nil)
+
+	for i := 0; i < 3; i++ {
+		svc.RecordQuery("Kafka exactly once")
+	}
+	got := svc.Complete("KAF", 4)
+	want := []string{"Kafka exactly once", "Kafka Consumers", "Kafka producers", "kafka"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, got)
+	}
+	for i := range want {
+		if got[i].Text != want[i] {
+			t.Fatalf("expected %v, got %v", want, completionTexts(got))
+		}
+	}
+	if got[0].Source != search.CompletionQuery || got[1].Source != search.CompletionTitle || got[3].Source != search.CompletionTerm {
+		t.Fatalf("unexpected sources %+v", got)
+	}
+
+	got = svc.Complete("raft elects lea", 5)
+	if len(got) != 1 || got[0].Text != "raft elects leader" {
+		t.Fatalf("expected the last word to be completed from terms, got %+v", got)
+	}
+	if got := svc.Complete("raft ", 5); len(got) != 0 {
+		t.Fatalf("expected no term completion after a trailing space, got %+v", got)
+	}
+	if got := svc.Complete("zzz", 5); len(got) != 0 {
+		t.Fatalf("expected no completions, got %+v", got)
+	}
+}
+
+func TestCompleteFollowsIndexChanges(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "1", Title: "Kafka", Content: "Kafka."})
+	svc := search.NewService(idx, nil)
+	svc.CompletionRebuildInterval = 0
+
+	if got := svc.Complete("elas", 5); len(got) != 0 {
+		t.Fatalf("expected no completions before indexing, got %+v", got)
+	}
+	idx.AddDocument(&docs.Document{ID: "2", Title: "Elasticsearch", Content: "Elasticsearch shards."})
+	idx.Refresh()
+	waitFor(t, func() bool { return len(svc.Complete("elas", 5)) == 1 })
+}
+
+func TestQueryHistoryIsBounded(t *testing.T) {
+	svc := search.NewService(nil, nil)
+	svc.QueryHistorySize = 2
+	svc.RecordQuery("kafka")
+	svc.RecordQuery("kafka")
+	svc.RecordQuery("kubernetes")
+	svc.RecordQuery("kotlin")
+	got := completionTexts(svc.Complete("k", 5))
+	if len(got) != 2 || got[0] != "kafka" || got[1] != "kotlin" {
+		t.Fatalf("expected the least frequent query to be evicted, got %v", got)
+	}
+
+	svc.QueryHistorySize = 0
+	svc.RecordQuery("kafka streams")
+	if got := svc.Complete("kafka s", 5); len(got) != 0 {
+		t.Fatalf("expected a zero history size to disable recording, got %v", got)
+	}
+}
+
+func BenchmarkComplete(b *testing.B) {
+	idx := index.NewInvertedIndex()
+	words := []string{"kafka", "kubernetes", "raft", "replication", "consensus", "partition", "shard", "search"}
+	for i := 0; i < 2000; i++ {
+		title := words[i%len(words)] + " " + words[(i/len(words))%len(words)]
+		idx.AddDocument(&docs.Document{ID: fmt.Sprintf("doc-%d", i), Title: title, Content: title})
+	}
+	svc := search.NewService(idx, nil)
+	svc.Complete("k", 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		svc.Complete("ka", 10)
+	}
+}
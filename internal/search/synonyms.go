@@ -0,0 +1,240 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/telemetry"
+)
+
+// Synonyms expands query terms with equivalent or more specific wordings. Entries are
+// analyzed when parsed, so they must use the analyzer of the index being searched.
+type Synonyms struct {
+	// rules are keyed by the first term of the text they match.
+	rules map[string][]synonymRule
+	count int
+}
+
+// synonymRule expands the terms of match. Expansions are kept as phrases so that
+// multi-word ones keep the positions of the stopwords between their terms.
+type synonymRule struct {
+	match      []string
+	expansions []Phrase
+}
+
+// ParseSynonyms reads a synonym file with one entry per line:
+//
+//	k8s, kubernetes                     # equivalent: each expands to the others
+//	ann => approximate nearest neighbor # one-way: only the left side is expanded
+//
+// Either side may list several comma-separated words or phrases. Blank lines and
+// text after # are ignored.
+func ParseSynonyms(r io.Reader, analyzer analysis.Analyzer) (*Synonyms, error) {
+	s := &Synonyms{rules: make(map[string][]synonymRule)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if comment := strings.IndexByte(text, '#'); comment >= 0 {
+			text = text[:comment]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if from, to, oneWay := strings.Cut(text, "=>"); oneWay {
+			sources, targets := analyzeSynonyms(from, analyzer), analyzeSynonyms(to, analyzer)
+			if len(sources) == 0 || len(targets) == 0 {
+				return nil, fmt.Errorf("synonyms line %d: one-way entry needs terms on both sides", line)
+			}
+			for _, source := range sources {
+				s.add(source.Terms, targets)
+			}
+			continue
+		}
+		group := analyzeSynonyms(text, analyzer)
+		if len(group) < 2 {
+			return nil, fmt.Errorf("synonyms line %d: equivalent entry needs at least two terms", line)
+		}
+		for i, source := range group {
+			others := make([]Phrase, 0, len(group)-1)
+			others = append(others, group[:i]...)
+			others = append(others, group[i+1:]...)
+			s.add(source.Terms, others)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSynonyms parses the synonym file at path.
+func LoadSynonyms(path string, analyzer analysis.Analyzer) (*Synonyms, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSynonyms(f, analyzer)
+}
+
+// analyzeSynonyms splits a comma-separated list and analyzes each entry. Entries that
+// analyze to nothing, such as stopwords, are dropped.
+func analyzeSynonyms(list string, analyzer analysis.Analyzer) []Phrase {
+	var out []Phrase
+	for _, entry := range strings.Split(list, ",") {
+		if terms, positions := analyzer.AnalyzePositions(entry); len(terms) > 0 {
+			out = append(out, Phrase{Terms: terms, Positions: phrasePositions(positions)})
+		}
+	}
+	return out
+}
+
+func (s *Synonyms) add(match []string, expansions []Phrase) {
+	s.rules[match[0]] = append(s.rules[match[0]], synonymRule{match: match, expansions: expansions})
+	s.count++
+}
+
+// Len returns the number of source entries, counting each member of an equivalent
+// group once.
+func (s *Synonyms) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.count
+}
+
+// Expand returns q with synonyms of its free terms added at the given weight. Runs of
+// consecutive terms in the same field are matched longest first, so a multi-word entry
+// wins over a single-word one. Single-word expansions become terms; multi-word
+// expansions become optional phrases. A nil Synonyms or a weight of zero leaves q
+// unchanged.
+func (s *Synonyms) Expand(q Query, weight float64) Query {
+	if s == nil || len(s.rules) == 0 || weight <= 0 {
+		return q
+	}
+	seen := make(map[string]bool, len(q.Terms))
+	for _, term := range q.Terms {
+		seen[term.Field+"\x00"+term.Text] = true
+	}
+	expanded := q
+	expanded.Terms = append([]Term(nil), q.Terms...)
+	expanded.Expansions = append([]Phrase(nil), q.Expansions...)
+	for i := 0; i < len(q.Terms); {
+		rules, n := s.match(q.Terms[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		field := q.Terms[i].Field
+		for _, rule := range rules {
+			for _, expansion := range rule.expansions {
+				key := field + "\x00" + strings.Join(expansion.Terms, " ")
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if len(expansion.Terms) == 1 {
+					expanded.Terms = append(expanded.Terms, Term{Text: expansion.Terms[0], Field: field, Weight: weight})
+				} else {
+					expansion.Field, expansion.Weight = field, weight
+					expanded.Expansions = append(expanded.Expansions, expansion)
+				}
+			}
+		}
+		i += n
+	}
+	return expanded
+}
+
+// match returns the rules matching the longest prefix of terms and its length.
+func (s *Synonyms) match(terms []Term) ([]synonymRule, int) {
+	var best []synonymRule
+	longest := 0
+	for _, rule := range s.rules[terms[0].Text] {
+		n := len(rule.match)
+		if n < longest || n > len(terms) {
+			continue
+		}
+		matched := true
+		for j := 1; j < n; j++ {
+			if terms[j].Text != rule.match[j] || terms[j].Field != terms[0].Field {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if n > longest {
+			best, longest = nil, n
+		}
+		best = append(best, rule)
+	}
+	return best, longest
+}
+
+// SetSynonyms replaces the synonyms used by Search. It is safe to call while searches
+// are running; nil disables expansion.
+func (s *Service) SetSynonyms(synonyms *Synonyms) {
+	s.synonyms.Store(synonyms)
+}
+
+// WatchSynonyms loads the synonym file at path and reloads it whenever its size or
+// modification time changes, until ctx is done. A change is only read once the file
+// has looked the same for a whole interval, so a file caught mid-write is not loaded.
+// A file that fails to load leaves the previous synonyms in place.
+func (s *Service) WatchSynonyms(ctx context.Context, path string, interval time.Duration, logger telemetry.Logger) {
+	type fileVersion struct {
+		mod  time.Time
+		size int64
+	}
+	// seen is the last version read, whether or not it parsed, so a broken file is
+	// reported once rather than on every tick.
+	var seen, pending fileVersion
+	statFailed := false
+	reload := func(settle bool) {
+		info, err := os.Stat(path)
+		if err != nil {
+			if !statFailed {
+				logger.Error("synonyms_reload_failed", err, "path", path)
+			}
+			statFailed = true
+			return
+		}
+		statFailed = false
+		current := fileVersion{mod: info.ModTime(), size: info.Size()}
+		if current == seen {
+			return
+		}
+		if settle && current != pending {
+			pending = current
+			return
+		}
+		seen = current
+		synonyms, err := LoadSynonyms(path, s.analyzer())
+		if err != nil {
+			logger.Error("synonyms_reload_failed", err, "path", path)
+			return
+		}
+		s.SetSynonyms(synonyms)
+		logger.Info("synonyms_loaded", "path", path, "entries", synonyms.Len())
+	}
+
+	reload(false)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload(true)
+		}
+	}
+}
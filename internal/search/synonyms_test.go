@@ -0,0 +1,132 @@
+package search_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
+	"github.com/eshwanth/distributed-search-engine/internal/docs"
+	"github.com/eshwanth/distributed-search-engine/internal/index"
+	"github.com/eshwanth/distributed-search-engine/internal/search"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+
+const testSynonyms = `
+# comment line
+k8s, kubernetes
+ann => approximate nearest neighbor, vector search
+approximate nearest neighbor => ann
+`
+
+func TestSynonymsExpandQueries(t *testing.T) {
+	synonyms, err := search.ParseSynonyms(strings.NewReader(testSynonyms), analysis.Standard())
+	if err != nil {
+		t.Fatalf("parse synonyms: %v", err)
+	}
+	if synonyms.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", synonyms.Len())
+	}
+
+	q := synonyms.Expand(search.ParseQuery("title:k8s ann", []string{index.FieldTitle}, analysis.Standard()), 0.5)
+	want := []search.Term{{Text: "k8s", Field: index.FieldTitle}, {Text: "ann"}, {Text: "kubernetes", Field: index.FieldTitle, Weight: 0.5}}
+	if len(q.Terms) != len(want) {
+		t.Fatalf("expected terms %v, got %v", want, q.Terms)
+	}
+	for i := range want {
+		if q.Terms[i] != want[i] {
+			t.Fatalf("expected terms %v, got %v", want, q.Terms)
+		}
+	}
+	if len(q.Expansions) != 2 || strings.Join(q.Expansions[0].Terms, " ") != "approximate nearest neighbor" || q.Expansions[1].Weight != 0.5 {
+		t.Fatalf("unexpected phrase expansions: %+v", q.Expansions)
+	}
+
+	q = synonyms.Expand(search.ParseQuery("approximate nearest neighbor search", nil, analysis.Standard()), 0.5)
+	if len(q.Terms) != 5 || q.Terms[4].Text != "ann" {
+		t.Fatalf("expected multi-word source to expand, got %v", q.Terms)
+	}
+	if q := synonyms.Expand(search.ParseQuery("kubernetes", nil, analysis.Standard()), 0); len(q.Terms) != 1 {
+		t.Fatalf("expected zero weight to disable expansion, got %v", q.Terms)
+	}
+
+	for _, bad := range []string{"lonely", "ann =>", "=> ann"} {
+		if _, err := search.ParseSynonyms(strings.NewReader(bad), analysis.Standard()); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSearchAppliesSynonymsWithLowerWeight(t *testing.T) {
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "kube", Title: "Kubernetes operators", Content: "Running stateful services on Kubernetes."})
+	idx.AddDocument(&docs.Document{ID: "k8s", Title: "K8s tips", Content: "Short notes about k8s rollouts."})
+	idx.AddDocument(&docs.Document{ID: "ann", Title: "Vector retrieval", Content: "Approximate nearest neighbor indexes trade recall for speed."})
+	idx.AddDocument(&docs.Document{ID: "other", Title: "Caching", Content: "Nearest cache wins."})
+	svc := search.NewService(idx, nil)
+
+	if results := svc.Search("k8s", 5); len(results) != 1 {
+		t.Fatalf("expected no expansion without synonyms, got %+v", results)
+	}
+	synonyms, err := search.ParseSynonyms(strings.NewReader(testSynonyms), idx.Analyzer)
+	if err != nil {
+		t.Fatalf("parse synonyms: %v", err)
+	}
+	svc.SetSynonyms(synonyms)
+
+	results := svc.Search("k8s", 5)
+	if len(results) != 2 || results[0].DocID != "k8s" || results[1].DocID != "kube" {
+		t.Fatalf("expected exact match first and synonym match second, got %+v", results)
+	}
+	results = svc.Search("ann", 5)
+	if len(results) != 1 || results[0].DocID != "ann" {
+		t.Fatalf("expected multi-word expansion to match as a phrase, got %+v", results)
+	}
+}
+
+func TestWatchSynonymsReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte("k8s, kubernetes\n"), 0o644); err != nil {
+		t.Fatalf("write synonyms: %v", err)
+	}
+	idx := index.NewInvertedIndex()
+	idx.AddDocument(&docs.Document{ID: "kube", Content: "kubernetes"})
+	idx.AddDocument(&docs.Document{ID: "pg", Content: "postgresql"})
+	svc := search.NewService(idx, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.WatchSynonyms(ctx, path, 5*time.Millisecond, nopLogger{})
+
+	waitFor(t, func() bool { return len(svc.Search("k8s", 5)) == 1 })
+	if err := os.WriteFile(path, []byte("k8s, kubernetes\npostgres, postgresql\n"), 0o644); err != nil {
+		t.Fatalf("rewrite synonyms: %v", err)
+	}
+	waitFor(t, func() bool { return len(svc.Search("postgres", 5)) == 1 })
+
+	if err := os.WriteFile(path, []byte("broken =>\n"), 0o644); err != nil {
+		t.Fatalf("break synonyms: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(svc.Search("postgres", 5)) != 1 {
+		t.Fatalf("expected a broken file to keep the previous synonyms, got %+v", svc.Search("postgres", 5))
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
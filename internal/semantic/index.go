@@ -1,6 +1,9 @@
 package semantic
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash/fnv"
 	"math"
 	"math/rand"
@@ -9,8 +12,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
-	"github.com/eshwanth/distributed-search-engine/internal/index"
 )
 
 // Vector represents an embedding vector.
@@ -37,8 +40,8 @@ func magnitude(a Vector) float64 {
 	return math.Sqrt(sum)
 }
 
-// cosineSimilarity returns cosine similarity between vectors.
-func cosineSimilarity(a, b Vector) float64 {
+// CosineSimilarity returns cosine similarity between vectors.
+func CosineSimilarity(a, b Vector) float64 {
 	mag := magnitude(a) * magnitude(b)
 	if mag == 0 {
 		return 0
@@ -54,12 +57,16 @@ type Embedder interface {
 
 // HashingEmbedder creates embeddings using a hashing trick for fixed-size vectors.
 type HashingEmbedder struct {
+	// Analyzer turns text into the tokens that are hashed. It should match the analyzer
+	// of the lexical index so both sides of hybrid search see the same terms.
+	Analyzer  analysis.Analyzer
 	dimension int
 }
 
-// NewHashingEmbedder constructs a hashing embedder with the provided dimension.
+// NewHashingEmbedder constructs a hashing embedder with the provided dimension and the
+// standard analyzer.
 func NewHashingEmbedder(dimension int) *HashingEmbedder {
-	return &HashingEmbedder{dimension: dimension}
+	return &HashingEmbedder{Analyzer: analysis.Standard(), dimension: dimension}
 }
 
 // EmbedTokens converts tokens into a hashed bag-of-words vector.
@@ -72,10 +79,9 @@ func (h *HashingEmbedder) EmbedTokens(tokens []string) Vector {
 	return vec
 }
 
-// EmbedText tokenizes the text using the index tokenizer and embeds it.
+// EmbedText analyzes the text and embeds its tokens.
 func (h *HashingEmbedder) EmbedText(text string) Vector {
-	tokens := index.Tokenize(text)
-	return h.EmbedTokens(tokens)
+	return h.EmbedTokens(h.Analyzer.Analyze(text))
 }
 
 func hashToken(token string) uint32 {
@@ -88,9 +94,11 @@ func hashToken(token string) uint32 {
 type Index struct {
 	mu          sync.RWMutex
 	embedder    Embedder
+	analyzer    analysis.Analyzer
 	hyperplanes []Vector
 	buckets     map[string]map[string]struct{}
 	vectors     map[string]Vector
+	signatures  map[string]string
 }
 
 // Options controls semantic index construction.
@@ -98,6 +106,8 @@ type Options struct {
 	Dimension       int
 	HyperplaneCount int
 	Seed            int64
+	// Analyzer tokenizes documents and queries; nil selects the standard analyzer.
+	Analyzer analysis.Analyzer
 }
 
 // NewIndex constructs a semantic index using hashing embeddings and random projections.
@@ -111,7 +121,11 @@ func NewIndex(opts Options) *Index {
 	if opts.Seed == 0 {
 		opts.Seed = time.Now().UnixNano()
 	}
+	if opts.Analyzer == nil {
+		opts.Analyzer = analysis.Standard()
+	}
 	embedder := NewHashingEmbedder(opts.Dimension)
+	embedder.Analyzer = opts.Analyzer
 	hyperplanes := make([]Vector, opts.HyperplaneCount)
 	rng := rand.New(rand.NewSource(opts.Seed))
 	for i := range hyperplanes {
@@ -123,9 +137,11 @@ func NewIndex(opts Options) *Index {
 	}
 	return &Index{
 		embedder:    embedder,
+		analyzer:    opts.Analyzer,
 		hyperplanes: hyperplanes,
 		buckets:     make(map[string]map[string]struct{}),
 		vectors:     make(map[string]Vector),
+		signatures:  make(map[string]string),
 	}
 }
 
@@ -151,7 +167,9 @@ func (i *Index) AddDocument(doc *docs.Document) {
 	sig := i.signature(vec)
 	i.mu.Lock()
 	defer i.mu.Unlock()
+	i.removeLocked(doc.ID)
 	i.vectors[doc.ID] = vec
+	i.signatures[doc.ID] = sig
 	bucket, ok := i.buckets[sig]
 	if !ok {
 		bucket = make(map[string]struct{})
@@ -160,6 +178,29 @@ func (i *Index) AddDocument(doc *docs.Document) {
 	bucket[doc.ID] = struct{}{}
 }
 
+// RemoveDocument drops a document's vector and bucket membership, reporting whether it was present.
+func (i *Index) RemoveDocument(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.removeLocked(id)
+}
+
+func (i *Index) removeLocked(id string) bool {
+	sig, ok := i.signatures[id]
+	if !ok {
+		return false
+	}
+	if bucket, ok := i.buckets[sig]; ok {
+		delete(bucket, id)
+		if len(bucket) == 0 {
+			delete(i.buckets, sig)
+		}
+	}
+	delete(i.signatures, id)
+	delete(i.vectors, id)
+	return true
+}
+
 // Result represents a semantic retrieval candidate.
 type Result struct {
 	DocID string
@@ -202,7 +243,7 @@ func (i *Index) Query(query string, topK int) []Result {
 		if vecDoc == nil {
 			continue
 		}
-		scores = append(scores, Result{DocID: id, Score: cosineSimilarity(vec, vecDoc)})
+		scores = append(scores, Result{DocID: id, Score: CosineSimilarity(vec, vecDoc)})
 	}
 	i.mu.RUnlock()
 
@@ -233,4 +274,146 @@ func (i *Index) Reset() {
 	defer i.mu.Unlock()
 	i.vectors = make(map[string]Vector)
 	i.buckets = make(map[string]map[string]struct{})
+	i.signatures = make(map[string]string)
+}
+
+// errCorruptIndex reports serialized semantic data that ends early or is inconsistent.
+var errCorruptIndex = errors.New("semantic: corrupt serialized index")
+
+// MarshalBinary serializes the analyzer name, hyperplanes, buckets, and vectors so the
+// index can be restored without re-embedding any document.
+func (i *Index) MarshalBinary() ([]byte, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	dimension := 0
+	if len(i.hyperplanes) > 0 {
+		dimension = len(i.hyperplanes[0])
+	}
+	buf := appendString(nil, i.analyzer.Name())
+	buf = binary.AppendUvarint(buf, uint64(dimension))
+	buf = binary.AppendUvarint(buf, uint64(len(i.hyperplanes)))
+	for _, plane := range i.hyperplanes {
+		buf = appendVector(buf, plane)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(i.buckets)))
+	for sig, bucket := range i.buckets {
+		buf = appendString(buf, sig)
+		buf = binary.AppendUvarint(buf, uint64(len(bucket)))
+		for id := range bucket {
+			buf = appendString(buf, id)
+			buf = appendVector(buf, i.vectors[id])
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the index contents with data from MarshalBinary, including
+// the hyperplanes, so query signatures match the stored buckets. It fails when the
+// data was embedded with a different analyzer than the index is configured with.
+func (i *Index) UnmarshalBinary(data []byte) error {
+	r := &byteReader{data: data}
+	if name := r.string(); r.err == nil && name != i.analyzer.Name() {
+		return fmt.Errorf("semantic: index was embedded with analyzer %q, want %q", name, i.analyzer.Name())
+	}
+	dimension := int(r.uvarint())
+	planes := make([]Vector, r.count())
+	for p := range planes {
+		planes[p] = r.vector(dimension)
+	}
+	buckets := make(map[string]map[string]struct{})
+	vectors := make(map[string]Vector)
+	signatures := make(map[string]string)
+	for b, n := 0, r.count(); b < n && r.err == nil; b++ {
+		sig := r.string()
+		members := r.count()
+		bucket := make(map[string]struct{}, members)
+		for m := 0; m < members && r.err == nil; m++ {
+			id := r.string()
+			bucket[id] = struct{}{}
+			vectors[id] = r.vector(dimension)
+			signatures[id] = sig
+		}
+		buckets[sig] = bucket
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	embedder := NewHashingEmbedder(dimension)
+	embedder.Analyzer = i.analyzer
+	i.embedder = embedder
+	i.hyperplanes = planes
+	i.buckets = buckets
+	i.vectors = vectors
+	i.signatures = signatures
+	return nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendVector(buf []byte, vec Vector) []byte {
+	for _, v := range vec {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	return buf
+}
+
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+func (r *byteReader) fail() {
+	r.err = errCorruptIndex
+	r.data = nil
+}
+
+func (r *byteReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a length, rejecting values larger than the remaining input.
+func (r *byteReader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(v)
+}
+
+func (r *byteReader) string() string {
+	n := r.count()
+	if n > len(r.data) {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *byteReader) vector(dimension int) Vector {
+	if len(r.data) < 8*dimension {
+		r.fail()
+		return nil
+	}
+	vec := make(Vector, dimension)
+	for d := range vec {
+		vec[d] = math.Float64frombits(binary.LittleEndian.Uint64(r.data[8*d:]))
+	}
+	r.data = r.data[8*dimension:]
+	return vec
 }
@@ -3,6 +3,7 @@ package semantic_test
 import (
 	"testing"
 
+	"github.com/eshwanth/distributed-search-engine/internal/analysis"
 	"github.com/eshwanth/distributed-search-engine/internal/docs"
 	"github.com/eshwanth/distributed-search-engine/internal/semantic"
 )
@@ -20,3 +21,53 @@ func TestSemanticIndexQueryReturnsNearestDocument(t *testing.T) {
 		t.Fatalf("expected embedding document to rank first, got %s", results[0].DocID)
 	}
 }
+
+func TestSemanticIndexRemoveDocument(t *testing.T) {
+	idx := semantic.NewIndex(semantic.Options{Dimension: 64, HyperplaneCount: 16, Seed: 7})
+	idx.AddDocument(&docs.Document{ID: "1", Content: "Vector embeddings enable semantic search."})
+	idx.AddDocument(&docs.Document{ID: "2", Content: "Caching strategies reduce tail latency."})
+
+	if !idx.RemoveDocument("1") {
+		t.Fatalf("expected document 1 to be removed")
+	}
+	if _, ok := idx.DocumentVector("1"); ok {
+		t.Fatalf("expected vector to be dropped")
+	}
+	for _, r := range idx.Query("semantic embeddings", 5) {
+		if r.DocID == "1" {
+			t.Fatalf("removed document returned by query")
+		}
+	}
+}
+
+func TestSemanticIndexBinaryRoundTrip(t *testing.T) {
+	idx := semantic.NewIndex(semantic.Options{Dimension: 64, HyperplaneCount: 16, Seed: 11})
+	idx.AddDocument(&docs.Document{ID: "1", Content: "Vector embeddings enable semantic search."})
+	idx.AddDocument(&docs.Document{ID: "2", Content: "Caching strategies reduce tail latency."})
+	data, err := idx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	restored := semantic.NewIndex(semantic.Options{Dimension: 128, HyperplaneCount: 24, Seed: 12})
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := idx.Query("semantic embeddings", 2)
+	got := restored.Query("semantic embeddings", 2)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("result %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if err := restored.UnmarshalBinary(data[:len(data)/2]); err == nil {
+		t.Fatalf("expected truncated data to fail")
+	}
+	english := semantic.NewIndex(semantic.Options{Dimension: 64, HyperplaneCount: 16, Seed: 13, Analyzer: analysis.English()})
+	if err := english.UnmarshalBinary(data); err == nil {
+		t.Fatalf("expected analyzer mismatch to fail")
+	}
+}
@@ -22,11 +22,36 @@ var (
 		Help: "Total number of crawl or parse errors.",
 	})
 
+	crawlerPruned = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_links_pruned_total",
+		Help: "Number of discovered links dropped by focused crawling as off-topic.",
+	})
+
 	indexUpdates = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "index_updates_total",
 		Help: "Number of documents ingested into the index.",
 	})
 
+	indexDeletes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "index_deletes_total",
+		Help: "Number of document deletions applied to the index.",
+	})
+
+	indexSegments = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "index_segments",
+		Help: "Number of immutable segments currently searchable.",
+	})
+
+	indexMerges = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "index_merges_total",
+		Help: "Number of background segment merges completed.",
+	})
+
+	indexPostingBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "index_postings_bytes",
+		Help: "Size of searchable postings, compressed and as an estimate of the uncompressed map representation.",
+	}, []string{"encoding"})
+
 	searchRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "search_requests_total",
 		Help: "Total search requests processed by the API.",
@@ -37,12 +62,27 @@ var (
 		Help:    "Latency distribution for search requests.",
 		Buckets: prometheus.DefBuckets,
 	})
+
+	kafkaHandlerRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kafka_handler_retries_total",
+		Help: "Number of times a failed document handler was retried.",
+	})
+
+	kafkaDeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_dead_letters_total",
+		Help: "Messages that failed for good and were dead-lettered or dropped, by the stage that failed.",
+	}, []string{"stage"})
+
+	searchNodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "search_node_failures_total",
+		Help: "Cluster nodes that failed or timed out during a coordinated search, by query phase.",
+	}, []string{"phase"})
 )
 
 // RegisterMetrics registers the Prometheus collectors once per process.
 func RegisterMetrics() {
 	once.Do(func() {
-		prometheus.MustRegister(crawlerDocs, crawlerErrors, indexUpdates, searchRequests, searchLatency)
+		prometheus.MustRegister(crawlerDocs, crawlerErrors, crawlerPruned, indexUpdates, indexDeletes, indexSegments, indexMerges, indexPostingBytes, kafkaHandlerRetries, kafkaDeadLetters, searchRequests, searchLatency, searchNodeFailures)
 	})
 }
 
@@ -64,15 +104,65 @@ func IncCrawlerErrors() {
 	crawlerErrors.Inc()
 }
 
+// IncCrawlerPruned increments the counter of links pruned by focused crawling.
+func IncCrawlerPruned() {
+	RegisterMetrics()
+	crawlerPruned.Inc()
+}
+
 // IncIndexUpdates increments the index update counter.
 func IncIndexUpdates() {
 	RegisterMetrics()
 	indexUpdates.Inc()
 }
 
+// IncIndexDeletes increments the index deletion counter.
+func IncIndexDeletes() {
+	RegisterMetrics()
+	indexDeletes.Inc()
+}
+
+// SetIndexSegments records the number of searchable index segments.
+func SetIndexSegments(n int) {
+	RegisterMetrics()
+	indexSegments.Set(float64(n))
+}
+
+// SetIndexPostingBytes records the compressed postings size next to the estimated size
+// of the same postings uncompressed.
+func SetIndexPostingBytes(compressed, uncompressed int) {
+	RegisterMetrics()
+	indexPostingBytes.WithLabelValues("compressed").Set(float64(compressed))
+	indexPostingBytes.WithLabelValues("uncompressed").Set(float64(uncompressed))
+}
+
+// IncIndexMerges increments the segment merge counter.
+func IncIndexMerges() {
+	RegisterMetrics()
+	indexMerges.Inc()
+}
+
 // ObserveSearch records request latency and status.
 func ObserveSearch(status string, latency time.Duration) {
 	RegisterMetrics()
 	searchRequests.WithLabelValues(status).Inc()
 	searchLatency.Observe(latency.Seconds())
 }
+
+// IncKafkaHandlerRetries counts a retry of a failed document handler.
+func IncKafkaHandlerRetries() {
+	RegisterMetrics()
+	kafkaHandlerRetries.Inc()
+}
+
+// IncKafkaDeadLetters counts a message that failed for good at the given stage.
+func IncKafkaDeadLetters(stage string) {
+	RegisterMetrics()
+	kafkaDeadLetters.WithLabelValues(stage).Inc()
+}
+
+// IncSearchNodeFailures counts a cluster node that failed one phase of a search.
+func IncSearchNodeFailures(phase string) {
+	RegisterMetrics()
+	searchNodeFailures.WithLabelValues(phase).Inc()
+}